@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+var (
+	_ bun.BeforeAppendModelHook = (*APIKey)(nil)
+	_ database.IndexedModel     = (*APIKey)(nil)
+)
+
+// APIKey is a long-lived credential for machine clients. Only the SHA-256
+// hash of the secret part of the key is stored.
+type APIKey struct {
+	bun.BaseModel `bun:"auth_api_keys"`
+	ID            string     `bun:"id,pk,notnull,type:varchar(32)"`
+	AuthUserID    string     `bun:"auth_user_id,notnull,type:varchar(32)"`
+	Name          string     `bun:"name,notnull,type:varchar(128)"`
+	Hash          string     `bun:"hash,notnull,type:varchar(64)"`
+	Scopes        string     `bun:"scopes,notnull,type:varchar(1024)"`
+	ExpiresAt     *time.Time `bun:"expires_at"`
+	LastUsedAt    *time.Time `bun:"last_used_at"`
+	RevokedAt     *time.Time `bun:"revoked_at"`
+	CreatedAt     time.Time  `bun:"created_at,notnull"`
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (k *APIKey) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		k.CreatedAt = time.Now()
+	}
+
+	return nil
+}
+
+// Indexes implements database.IndexedModel.
+func (k *APIKey) Indexes() []database.Index {
+	return []database.Index{
+		{Name: "auth_api_keys_auth_user_id_idx", Columns: []string{"auth_user_id", "created_at"}},
+	}
+}
+
+// Active reports whether the key is neither revoked nor expired at t.
+func (k *APIKey) Active(t time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+
+	if k.ExpiresAt != nil && !t.Before(*k.ExpiresAt) {
+		return false
+	}
+
+	return true
+}
+
+// ScopeList returns the scopes of the key as a slice.
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
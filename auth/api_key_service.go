@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+const (
+	DefaultAPIKeyPrefix = "mo"
+
+	apiKeyAlphabet      = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	apiKeyIDLength      = 16
+	apiKeySecretLength  = 40
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+// CreateAPIKey issues a new API key for an auth user. The plain key is only
+// returned once and cannot be recovered afterwards.
+func (s *Service) CreateAPIKey(ctx context.Context, dto *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := db.NewSelect().
+		Model((*User)(nil)).
+		Where("id = ?", dto.AuthUserID).
+		Exists(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	id, err := gonanoid.Generate(apiKeyAlphabet, apiKeyIDLength)
+
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := gonanoid.Generate(apiKeyAlphabet, apiKeySecretLength)
+
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey := APIKey{
+		ID:         id,
+		AuthUserID: dto.AuthUserID,
+		Name:       dto.Name,
+		Hash:       hashSecret(secret),
+		Scopes:     strings.Join(dto.Scopes, " "),
+		ExpiresAt:  dto.ExpiresAt,
+	}
+
+	if _, err := db.NewInsert().Model(&apiKey).Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return &CreateAPIKeyResponse{
+		ID:        apiKey.ID,
+		Key:       s.formatAPIKey(id, secret),
+		ExpiresAt: apiKey.ExpiresAt,
+	}, nil
+}
+
+// ListAPIKeys returns every API key of an auth user, including revoked and
+// expired ones.
+func (s *Service) ListAPIKeys(ctx context.Context, authUserId string) ([]APIKeyResponse, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var apiKeys []APIKey
+
+	err = db.NewSelect().
+		Model(&apiKeys).
+		Where("auth_user_id = ?", authUserId).
+		Order("created_at DESC").
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]APIKeyResponse, 0, len(apiKeys))
+
+	for _, apiKey := range apiKeys {
+		res = append(res, APIKeyResponse{
+			ID:         apiKey.ID,
+			Name:       apiKey.Name,
+			Scopes:     apiKey.ScopeList(),
+			ExpiresAt:  apiKey.ExpiresAt,
+			LastUsedAt: apiKey.LastUsedAt,
+			RevokedAt:  apiKey.RevokedAt,
+			CreatedAt:  apiKey.CreatedAt,
+		})
+	}
+
+	return res, nil
+}
+
+// RevokeAPIKey revokes a single API key of an auth user.
+func (s *Service) RevokeAPIKey(ctx context.Context, authUserId string, apiKeyId string) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := db.NewUpdate().
+		Model((*APIKey)(nil)).
+		Where("id = ?", apiKeyId).
+		Where("auth_user_id = ?", authUserId).
+		Where("revoked_at IS NULL").
+		Set("revoked_at = ?", time.Now()).
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// VerifyAPIKey verifies an API key and returns the auth user it belongs to.
+func (s *Service) VerifyAPIKey(ctx context.Context, key string) (*VerifyResponse, error) {
+
+	id, secret, ok := s.parseAPIKey(key)
+
+	if !ok {
+		return nil, ErrBadToken
+	}
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var apiKey APIKey
+
+	err = db.NewSelect().Model(&apiKey).Where("id = ?", id).Limit(1).Scan(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBadToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(apiKey.Hash), []byte(hashSecret(secret))) != 1 {
+		return nil, ErrBadToken
+	}
+
+	now := time.Now()
+
+	if !apiKey.Active(now) {
+		return nil, ErrBadToken
+	}
+
+	var user User
+
+	err = db.NewSelect().Model(&user).Where("id = ?", apiKey.AuthUserID).Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Track usage, but avoid a write on every request
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval {
+		_, err = db.NewUpdate().
+			Model((*APIKey)(nil)).
+			Where("id = ?", apiKey.ID).
+			Set("last_used_at = ?", now).
+			Exec(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &VerifyResponse{
+		AuthUserID: user.ID,
+		UserID:     user.UserID,
+		APIKeyID:   &apiKey.ID,
+		Scopes:     apiKey.ScopeList(),
+	}, nil
+}
+
+// IsAPIKey reports whether token looks like an API key issued by this
+// service rather than a JWT.
+func (s *Service) IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, s.apiKeyPrefix()+"_")
+}
+
+func (s *Service) formatAPIKey(id string, secret string) string {
+	return s.apiKeyPrefix() + "_" + id + "_" + secret
+}
+
+func (s *Service) parseAPIKey(key string) (string, string, bool) {
+
+	if !s.IsAPIKey(key) {
+		return "", "", false
+	}
+
+	id, secret, ok := strings.Cut(strings.TrimPrefix(key, s.apiKeyPrefix()+"_"), "_")
+
+	if !ok || len(id) != apiKeyIDLength || len(secret) != apiKeySecretLength {
+		return "", "", false
+	}
+
+	return id, secret, true
+}
+
+func (s *Service) apiKeyPrefix() string {
+	if s.cfg.APIKeyPrefix == "" {
+		return DefaultAPIKeyPrefix
+	}
+
+	return s.cfg.APIKeyPrefix
+}
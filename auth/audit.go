@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"github.com/joelywz/mo/database"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+var (
+	_ bun.BeforeAppendModelHook = (*AuditEvent)(nil)
+	_ database.IndexedModel     = (*AuditEvent)(nil)
+)
+
+type AuditEventType string
+
+const (
+	AuditLoginSucceeded       AuditEventType = "LOGIN_SUCCEEDED"
+	AuditLoginFailed          AuditEventType = "LOGIN_FAILED"
+	AuditRegistered           AuditEventType = "REGISTERED"
+	AuditRevoked              AuditEventType = "REVOKED"
+	AuditLinked               AuditEventType = "LINKED"
+	AuditPasswordChanged      AuditEventType = "PASSWORD_CHANGED"
+	AuditPasswordChangeFailed AuditEventType = "PASSWORD_CHANGE_FAILED"
+)
+
+// AuditEvent is a security relevant event emitted by Service.
+type AuditEvent struct {
+	bun.BaseModel `bun:"auth_audit_events"`
+	ID            string         `bun:"id,pk,notnull,type:varchar(32)"`
+	Type          AuditEventType `bun:"type,notnull,type:varchar(32)"`
+	AuthUserID    *string        `bun:"auth_user_id,type:varchar(32)"`
+	Email         *string        `bun:"email,type:varchar(320)"`
+	Reason        string         `bun:"reason,notnull,type:varchar(255)"`
+	IP            string         `bun:"ip,notnull,type:varchar(45)"`
+	UserAgent     string         `bun:"user_agent,notnull,type:varchar(512)"`
+	RequestID     string         `bun:"request_id,notnull,type:varchar(64)"`
+	CreatedAt     time.Time      `bun:"created_at,notnull"`
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (e *AuditEvent) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		if e.CreatedAt.IsZero() {
+			e.CreatedAt = time.Now()
+		}
+	}
+
+	return nil
+}
+
+// Indexes implements database.IndexedModel.
+func (e *AuditEvent) Indexes() []database.Index {
+	return []database.Index{
+		{Name: "auth_audit_events_auth_user_id_idx", Columns: []string{"auth_user_id", "created_at"}},
+		{Name: "auth_audit_events_created_at_idx", Columns: []string{"created_at"}},
+	}
+}
+
+// AuditSink receives the audit events emitted by Service.
+type AuditSink interface {
+	Record(ctx context.Context, event *AuditEvent) error
+}
+
+// record emits an audit event enriched with the request info from the
+// context. Failing to record is logged rather than failing the operation.
+func (s *Service) record(ctx context.Context, event AuditEvent) {
+
+	if s.audit == nil {
+		return
+	}
+
+	info := RequestInfoFromContext(ctx)
+
+	// The request info comes from client headers, cut it to the column
+	// sizes rather than failing the insert on strict databases
+	event.ID = gonanoid.Must(32)
+	event.IP = truncate(info.IP, 45)
+	event.UserAgent = truncate(info.UserAgent, 512)
+	event.RequestID = truncate(info.RequestID, 64)
+	event.Reason = truncate(event.Reason, 255)
+
+	if err := s.audit.Record(ctx, &event); err != nil {
+		slog.Error("recording audit event", "type", event.Type, "error", err)
+	}
+}
+
+// truncate cuts s to at most n characters.
+func truncate(s string, n int) string {
+
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	return string([]rune(s)[:n])
+}
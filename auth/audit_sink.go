@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+var _ AuditSink = (*DBAuditSink)(nil)
+
+// DBAuditSink stores audit events in the auth_audit_events table. It writes
+// through its own connection rather than the one in the context, so events
+// such as failed logins survive a rolled back request transaction.
+type DBAuditSink struct {
+	db bun.IDB
+}
+
+func NewDBAuditSink(db bun.IDB) *DBAuditSink {
+	return &DBAuditSink{
+		db: db,
+	}
+}
+
+// Record implements AuditSink.
+func (s *DBAuditSink) Record(ctx context.Context, event *AuditEvent) error {
+	_, err := s.db.NewInsert().Model(event).Exec(ctx)
+	return err
+}
+
+type AuditQuery struct {
+	AuthUserID string
+	Types      []AuditEventType
+	// Before only returns events created before this time, for paging.
+	Before time.Time
+	Limit  int
+}
+
+// Query returns audit events matching q, newest first.
+func (s *DBAuditSink) Query(ctx context.Context, q *AuditQuery) ([]AuditEvent, error) {
+
+	events := []AuditEvent{}
+
+	query := s.db.NewSelect().
+		Model(&events).
+		Order("created_at DESC", "id DESC")
+
+	if q.AuthUserID != "" {
+		query = query.Where("auth_user_id = ?", q.AuthUserID)
+	}
+
+	if len(q.Types) > 0 {
+		query = query.Where("type IN (?)", bun.In(q.Types))
+	}
+
+	if !q.Before.IsZero() {
+		query = query.Where("created_at < ?", q.Before)
+	}
+
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+
+	if err := query.Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// RecentActivity returns the latest audit events of an auth user.
+func (s *DBAuditSink) RecentActivity(ctx context.Context, authUserId string, limit int) ([]AuditEvent, error) {
+	return s.Query(ctx, &AuditQuery{
+		AuthUserID: authUserId,
+		Limit:      limit,
+	})
+}
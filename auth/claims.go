@@ -7,6 +7,9 @@ type TokenClaims struct {
 	ID      string    `json:"id"`
 	Type    TokenType `json:"type"`
 	Version string    `json:"version"`
+	Scope   string    `json:"scope,omitempty"`
+	Roles   []string  `json:"roles,omitempty"`
+	OrgID   string    `json:"org,omitempty"`
 }
 
 type TokenType string
@@ -10,6 +10,8 @@ type Config struct {
 	Secret          string        `env:"AUTH_SECRET"`
 	AccessDuration  time.Duration `env:"AUTH_ACCESS_DURATION" envDefault:"10m"`
 	RefreshDuration time.Duration `env:"AUTH_REFRESH_DURATION" envDefault:"2160h"`
+	APIKeyPrefix    string        `env:"AUTH_API_KEY_PREFIX" envDefault:"mo"`
+	InviteDuration  time.Duration `env:"AUTH_INVITE_DURATION" envDefault:"168h"`
 }
 
 func ParseConfig() (*Config, error) {
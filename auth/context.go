@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+type VerifyKey struct{}
+
+var (
+	ErrNoVerifyInContext = errors.New("no verify response in context")
+)
+
+// WithContext returns a new context carrying the verified identity.
+func WithContext(ctx context.Context, res *VerifyResponse) context.Context {
+	return context.WithValue(ctx, VerifyKey{}, res)
+}
+
+// FromContext retrieves the verified identity from the context.
+// Returns ErrNoVerifyInContext if the request was not authenticated.
+func FromContext(ctx context.Context) (*VerifyResponse, error) {
+	res, ok := ctx.Value(VerifyKey{}).(*VerifyResponse)
+
+	if !ok {
+		return nil, ErrNoVerifyInContext
+	}
+	return res, nil
+}
@@ -1,6 +1,9 @@
 package auth
 
-import "time"
+import (
+	"slices"
+	"time"
+)
 
 type RegisterRequest struct {
 	Email    string `json:"email"`
@@ -22,6 +25,12 @@ type LoginResponse struct {
 	UserID     *string `json:"userId"`
 }
 
+type ChangePasswordRequest struct {
+	Email       string `json:"email"`
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
 type TokenResponse struct {
 	RefreshToken  string    `json:"refreshToken"`
 	AccessToken   string    `json:"accessToken"`
@@ -30,11 +39,86 @@ type TokenResponse struct {
 }
 
 type VerifyResponse struct {
-	AuthUserID string  `json:"authUserId"`
-	UserID     *string `json:"userId"`
+	AuthUserID string   `json:"authUserId"`
+	UserID     *string  `json:"userId"`
+	APIKeyID   *string  `json:"apiKeyId,omitempty"`
+	Scopes     []string `json:"scopes,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+
+	OrganizationID   *string    `json:"organizationId,omitempty"`
+	OrganizationRole MemberRole `json:"organizationRole,omitempty"`
+}
+
+// HasScope reports whether the verified identity was granted scope.
+func (v *VerifyResponse) HasScope(scope string) bool {
+	return slices.Contains(v.Scopes, scope)
+}
+
+// HasRole reports whether the verified identity was granted role.
+func (v *VerifyResponse) HasRole(role string) bool {
+	return slices.Contains(v.Roles, role)
 }
 
 type LinkRequest struct {
 	AuthUserID string `json:"authUserId"`
 	UserID     string `json:"userId"`
 }
+
+type CreateAPIKeyRequest struct {
+	AuthUserID string     `json:"authUserId"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+}
+
+type CreateAPIKeyResponse struct {
+	ID        string     `json:"id"`
+	Key       string     `json:"key"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type CreateOrganizationRequest struct {
+	AuthUserID string `json:"authUserId"`
+	Name       string `json:"name"`
+}
+
+type OrganizationResponse struct {
+	ID   string     `json:"id"`
+	Name string     `json:"name"`
+	Role MemberRole `json:"role"`
+}
+
+type MemberResponse struct {
+	AuthUserID string     `json:"authUserId"`
+	UserID     *string    `json:"userId"`
+	Role       MemberRole `json:"role"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type InviteRequest struct {
+	OrganizationID string     `json:"organizationId"`
+	Email          string     `json:"email"`
+	Role           MemberRole `json:"role"`
+	InvitedBy      string     `json:"invitedBy"`
+}
+
+type InviteResponse struct {
+	ID        string    `json:"id"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type AcceptInvitationRequest struct {
+	Token      string `json:"token"`
+	AuthUserID string `json:"authUserId"`
+}
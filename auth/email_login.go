@@ -14,7 +14,7 @@ type EmailLogin struct {
 	bun.BaseModel `bun:"email_logins"`
 	Email         string    `bun:"email,pk,notnull,type:varchar(320)"`
 	Password      string    `bun:"password,notnull,type:varchar(128)"`
-	AuthUserID    string    `bun:"auth_user_id,type:varchar(32)"`
+	AuthUserID    string    `bun:"auth_user_id,notnull,unique,type:varchar(32)"`
 	CreatedAt     time.Time `bun:"created_at,notnull"`
 	UpdatedAt     time.Time `bun:"updated_at,notnull"`
 }
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/joelywz/mo/database"
+)
+
+type RegisteredEvent struct {
+	AuthUserID string
+	Email      string
+}
+
+type LinkedEvent struct {
+	AuthUserID string
+	UserID     string
+}
+
+type RevokedEvent struct {
+	AuthUserID string
+}
+
+// Hooks holds the hooks fired by Service.
+type Hooks struct {
+	Registered Hook[RegisteredEvent]
+	Linked     Hook[LinkedEvent]
+	Revoked    Hook[RevokedEvent]
+}
+
+// Hook is a registry of hooks for events of type E.
+type Hook[E any] struct {
+	mu     sync.RWMutex
+	before []func(ctx context.Context, event E) error
+	after  []func(ctx context.Context, event E)
+}
+
+// Before registers a synchronous hook that runs inside the transaction of
+// the operation, with the transaction available through
+// database.FromContext. Returning an error vetoes the operation, which is
+// rolled back and fails with that error.
+func (h *Hook[E]) Before(fn func(ctx context.Context, event E) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.before = append(h.before, fn)
+}
+
+// After registers an asynchronous hook that runs in its own goroutine once
+// the operation has committed, see database.AfterCommit.
+func (h *Hook[E]) After(fn func(ctx context.Context, event E)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.after = append(h.after, fn)
+}
+
+func (h *Hook[E]) runBefore(ctx context.Context, event E) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, fn := range h.before {
+		if err := fn(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *Hook[E]) scheduleAfter(ctx context.Context, event E) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, fn := range h.after {
+		database.AfterCommit(ctx, func(ctx context.Context) {
+			go runAfter(context.WithoutCancel(ctx), fn, event)
+		})
+	}
+}
+
+// runAfter runs an after hook, logging a panic rather than crashing the
+// process from its goroutine.
+func runAfter[E any](ctx context.Context, fn func(ctx context.Context, event E), event E) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("auth after hook panic", "error", r)
+		}
+	}()
+
+	fn(ctx, event)
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/joelywz/mo/database"
+	"github.com/labstack/echo/v4"
+)
+
+// Middleware authenticates the request using the bearer token in the
+// Authorization header, which may either be an access token or an API key.
+// The resulting VerifyResponse is stored in the request context and can be
+// retrieved with FromContext. It requires a database connection in the
+// context, see database.GlobalMiddleware.
+func Middleware(s *Service) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			token, ok := bearerToken(c.Request())
+
+			if !ok {
+				return echo.ErrUnauthorized
+			}
+
+			var (
+				res *VerifyResponse
+				err error
+			)
+
+			if s.IsAPIKey(token) {
+				res, err = s.VerifyAPIKey(ctx, token)
+			} else {
+				res, err = s.Verify(ctx, token, TokenTypeAccess)
+			}
+
+			if errors.Is(err, ErrBadToken) || errors.Is(err, ErrNotFound) {
+				return echo.ErrUnauthorized.WithInternal(err)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			ctx = WithContext(ctx, res)
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
+
+// RequireScope rejects requests whose verified identity lacks scope with
+// 403 Forbidden. It must run after Middleware.
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return require(func(res *VerifyResponse) bool {
+		return res.HasScope(scope)
+	})
+}
+
+// RequireRole rejects requests whose verified identity lacks role with
+// 403 Forbidden. It must run after Middleware.
+func RequireRole(role string) echo.MiddlewareFunc {
+	return require(func(res *VerifyResponse) bool {
+		return res.HasRole(role)
+	})
+}
+
+// RequireOrganization rejects requests without an active organization with
+// 403 Forbidden and scopes the request context to the active organization
+// as tenant, see database.ScopeTenant. It must run after Middleware.
+func RequireOrganization() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			res, err := FromContext(ctx)
+
+			if err != nil {
+				return echo.ErrUnauthorized.WithInternal(err)
+			}
+
+			if res.OrganizationID == nil {
+				return echo.ErrForbidden
+			}
+
+			ctx = database.WithTenant(ctx, *res.OrganizationID)
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
+
+func require(allowed func(res *VerifyResponse) bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			res, err := FromContext(c.Request().Context())
+
+			if err != nil {
+				return echo.ErrUnauthorized.WithInternal(err)
+			}
+
+			if !allowed(res) {
+				return echo.ErrForbidden
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get(echo.HeaderAuthorization), " ")
+
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return token, true
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"embed"
+	"fmt"
+
+	"github.com/joelywz/mo/database"
+	"github.com/uptrace/bun/migrate"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migrations creates and evolves the tables of the auth package. The SQL
+// run depends on the dialect of the database being migrated, see
+// database.DialectMigrations.
+var Migrations *migrate.Migrations
+
+func init() {
+
+	var err error
+
+	if Migrations, err = database.DialectMigrations(migrationFiles, "migrations"); err != nil {
+		panic(fmt.Sprintf("auth: %s", err))
+	}
+}
+
+// RegisterMigrations adds the auth migrations to m, so that they run
+// alongside the migrations of the app:
+//
+//	migrations := migrate.NewMigrations()
+//	auth.RegisterMigrations(migrations)
+//	database.MigrateUp(manager, migrations)
+//
+// The auth migrations are named after the time they were written, like
+// the ones created with database.CreateMigration.
+func RegisterMigrations(m *migrate.Migrations) {
+	for _, migration := range Migrations.Sorted() {
+		m.Add(migration)
+	}
+}
+
+// Models returns the models of the auth package, to check them against the
+// database with database.DetectDrift.
+func Models() []any {
+	return []any{
+		(*User)(nil),
+		(*EmailLogin)(nil),
+		(*APIKey)(nil),
+		(*UserRole)(nil),
+		(*UserScope)(nil),
+		(*Organization)(nil),
+		(*Membership)(nil),
+		(*Invitation)(nil),
+		(*AuditEvent)(nil),
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS email_logins;
+
+--bun:split
+
+DROP TABLE IF EXISTS auth_users;
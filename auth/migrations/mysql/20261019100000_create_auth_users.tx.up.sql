@@ -0,0 +1,21 @@
+CREATE TABLE auth_users (
+  id VARCHAR(32) NOT NULL,
+  user_id VARCHAR(32),
+  version VARCHAR(32) NOT NULL,
+  created_at DATETIME NOT NULL,
+  PRIMARY KEY (id),
+  INDEX auth_users_user_id_idx (user_id)
+);
+
+--bun:split
+
+CREATE TABLE email_logins (
+  email VARCHAR(320) NOT NULL,
+  password VARCHAR(128) NOT NULL,
+  auth_user_id VARCHAR(32) NOT NULL,
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL,
+  PRIMARY KEY (email),
+  UNIQUE INDEX email_logins_auth_user_id_idx (auth_user_id),
+  CONSTRAINT email_logins_auth_user_id_fk FOREIGN KEY (auth_user_id) REFERENCES auth_users (id) ON DELETE CASCADE
+);
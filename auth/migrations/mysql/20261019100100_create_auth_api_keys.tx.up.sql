@@ -0,0 +1,14 @@
+CREATE TABLE auth_api_keys (
+  id VARCHAR(32) NOT NULL,
+  auth_user_id VARCHAR(32) NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  hash VARCHAR(64) NOT NULL,
+  scopes VARCHAR(1024) NOT NULL,
+  expires_at DATETIME,
+  last_used_at DATETIME,
+  revoked_at DATETIME,
+  created_at DATETIME NOT NULL,
+  PRIMARY KEY (id),
+  INDEX auth_api_keys_auth_user_id_idx (auth_user_id, created_at),
+  CONSTRAINT auth_api_keys_auth_user_id_fk FOREIGN KEY (auth_user_id) REFERENCES auth_users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS auth_user_scopes;
+
+--bun:split
+
+DROP TABLE IF EXISTS auth_user_roles;
@@ -0,0 +1,39 @@
+CREATE TABLE auth_organizations (
+  id VARCHAR(32) NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL,
+  PRIMARY KEY (id)
+);
+
+--bun:split
+
+CREATE TABLE auth_memberships (
+  organization_id VARCHAR(32) NOT NULL,
+  auth_user_id VARCHAR(32) NOT NULL,
+  role VARCHAR(16) NOT NULL,
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL,
+  PRIMARY KEY (organization_id, auth_user_id),
+  INDEX auth_memberships_auth_user_id_idx (auth_user_id),
+  CONSTRAINT auth_memberships_organization_id_fk FOREIGN KEY (organization_id) REFERENCES auth_organizations (id) ON DELETE CASCADE,
+  CONSTRAINT auth_memberships_auth_user_id_fk FOREIGN KEY (auth_user_id) REFERENCES auth_users (id) ON DELETE CASCADE
+);
+
+--bun:split
+
+CREATE TABLE auth_invitations (
+  id VARCHAR(32) NOT NULL,
+  organization_id VARCHAR(32) NOT NULL,
+  email VARCHAR(320) NOT NULL,
+  role VARCHAR(16) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL,
+  invited_by VARCHAR(32) NOT NULL,
+  expires_at DATETIME NOT NULL,
+  accepted_at DATETIME,
+  created_at DATETIME NOT NULL,
+  PRIMARY KEY (id),
+  UNIQUE INDEX auth_invitations_token_hash_idx (token_hash),
+  INDEX auth_invitations_organization_id_idx (organization_id),
+  CONSTRAINT auth_invitations_organization_id_fk FOREIGN KEY (organization_id) REFERENCES auth_organizations (id) ON DELETE CASCADE
+);
@@ -0,0 +1,14 @@
+CREATE TABLE auth_audit_events (
+  id VARCHAR(32) NOT NULL,
+  type VARCHAR(32) NOT NULL,
+  auth_user_id VARCHAR(32),
+  email VARCHAR(320),
+  reason VARCHAR(255) NOT NULL,
+  ip VARCHAR(45) NOT NULL,
+  user_agent VARCHAR(512) NOT NULL,
+  request_id VARCHAR(64) NOT NULL,
+  created_at DATETIME NOT NULL,
+  PRIMARY KEY (id),
+  INDEX auth_audit_events_auth_user_id_idx (auth_user_id, created_at),
+  INDEX auth_audit_events_created_at_idx (created_at)
+);
@@ -0,0 +1,27 @@
+CREATE TABLE auth_users (
+  id VARCHAR(32) NOT NULL,
+  user_id VARCHAR(32),
+  version VARCHAR(32) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (id)
+);
+
+--bun:split
+
+CREATE INDEX auth_users_user_id_idx ON auth_users (user_id);
+
+--bun:split
+
+CREATE TABLE email_logins (
+  email VARCHAR(320) NOT NULL,
+  password VARCHAR(128) NOT NULL,
+  auth_user_id VARCHAR(32) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (email),
+  CONSTRAINT email_logins_auth_user_id_fk FOREIGN KEY (auth_user_id) REFERENCES auth_users (id) ON DELETE CASCADE
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX email_logins_auth_user_id_idx ON email_logins (auth_user_id);
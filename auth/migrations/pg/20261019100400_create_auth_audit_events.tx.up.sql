@@ -0,0 +1,20 @@
+CREATE TABLE auth_audit_events (
+  id VARCHAR(32) NOT NULL,
+  type VARCHAR(32) NOT NULL,
+  auth_user_id VARCHAR(32),
+  email VARCHAR(320),
+  reason VARCHAR(255) NOT NULL,
+  ip VARCHAR(45) NOT NULL,
+  user_agent VARCHAR(512) NOT NULL,
+  request_id VARCHAR(64) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (id)
+);
+
+--bun:split
+
+CREATE INDEX auth_audit_events_auth_user_id_idx ON auth_audit_events (auth_user_id, created_at);
+
+--bun:split
+
+CREATE INDEX auth_audit_events_created_at_idx ON auth_audit_events (created_at);
@@ -0,0 +1,17 @@
+CREATE TABLE auth_api_keys (
+  id VARCHAR(32) NOT NULL,
+  auth_user_id VARCHAR(32) NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  hash VARCHAR(64) NOT NULL,
+  scopes VARCHAR(1024) NOT NULL,
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP,
+  revoked_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT auth_api_keys_auth_user_id_fk FOREIGN KEY (auth_user_id) REFERENCES auth_users (id) ON DELETE CASCADE
+);
+
+--bun:split
+
+CREATE INDEX auth_api_keys_auth_user_id_idx ON auth_api_keys (auth_user_id, created_at);
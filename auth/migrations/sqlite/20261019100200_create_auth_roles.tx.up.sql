@@ -0,0 +1,17 @@
+CREATE TABLE auth_user_roles (
+  auth_user_id VARCHAR(32) NOT NULL,
+  role VARCHAR(64) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (auth_user_id, role),
+  CONSTRAINT auth_user_roles_auth_user_id_fk FOREIGN KEY (auth_user_id) REFERENCES auth_users (id) ON DELETE CASCADE
+);
+
+--bun:split
+
+CREATE TABLE auth_user_scopes (
+  auth_user_id VARCHAR(32) NOT NULL,
+  scope VARCHAR(128) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (auth_user_id, scope),
+  CONSTRAINT auth_user_scopes_auth_user_id_fk FOREIGN KEY (auth_user_id) REFERENCES auth_users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS auth_invitations;
+
+--bun:split
+
+DROP TABLE IF EXISTS auth_memberships;
+
+--bun:split
+
+DROP TABLE IF EXISTS auth_organizations;
@@ -0,0 +1,48 @@
+CREATE TABLE auth_organizations (
+  id VARCHAR(32) NOT NULL,
+  name VARCHAR(128) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (id)
+);
+
+--bun:split
+
+CREATE TABLE auth_memberships (
+  organization_id VARCHAR(32) NOT NULL,
+  auth_user_id VARCHAR(32) NOT NULL,
+  role VARCHAR(16) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (organization_id, auth_user_id),
+  CONSTRAINT auth_memberships_organization_id_fk FOREIGN KEY (organization_id) REFERENCES auth_organizations (id) ON DELETE CASCADE,
+  CONSTRAINT auth_memberships_auth_user_id_fk FOREIGN KEY (auth_user_id) REFERENCES auth_users (id) ON DELETE CASCADE
+);
+
+--bun:split
+
+CREATE INDEX auth_memberships_auth_user_id_idx ON auth_memberships (auth_user_id);
+
+--bun:split
+
+CREATE TABLE auth_invitations (
+  id VARCHAR(32) NOT NULL,
+  organization_id VARCHAR(32) NOT NULL,
+  email VARCHAR(320) NOT NULL,
+  role VARCHAR(16) NOT NULL,
+  token_hash VARCHAR(64) NOT NULL,
+  invited_by VARCHAR(32) NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  accepted_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (id),
+  CONSTRAINT auth_invitations_organization_id_fk FOREIGN KEY (organization_id) REFERENCES auth_organizations (id) ON DELETE CASCADE
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX auth_invitations_token_hash_idx ON auth_invitations (token_hash);
+
+--bun:split
+
+CREATE INDEX auth_invitations_organization_id_idx ON auth_invitations (organization_id);
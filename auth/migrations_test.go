@@ -0,0 +1,74 @@
+package auth_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/joelywz/mo/auth"
+	"github.com/joelywz/mo/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations(t *testing.T) {
+
+	t.Run("Dialects", func(t *testing.T) {
+		var reference []string
+
+		for _, dialect := range []string{"mysql", "pg", "sqlite"} {
+			entries, err := os.ReadDir("migrations/" + dialect)
+			assert.NoError(t, err)
+
+			var names []string
+
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+
+			if reference == nil {
+				reference = names
+				continue
+			}
+
+			assert.Equal(t, reference, names, "%s should have the same migrations", dialect)
+		}
+
+		assert.Len(t, auth.Migrations.Sorted(), len(reference)/2)
+	})
+
+	t.Run("Drift", func(t *testing.T) {
+		drifts, err := database.DetectDrift(context.Background(), db, auth.Models()...)
+		assert.NoError(t, err)
+		assert.Empty(t, drifts, "migrations should match the models")
+	})
+
+	t.Run("Constraints", func(t *testing.T) {
+		ctx := context.Background()
+
+		_, err := db.NewInsert().Model(&auth.EmailLogin{
+			Email:      "orphan@email.com",
+			Password:   "password",
+			AuthUserID: "missing",
+		}).Exec(ctx)
+		assert.Error(t, err, "email logins should reference an auth user")
+
+		user := auth.User{ID: "constraints", Version: "1"}
+
+		_, err = db.NewInsert().Model(&user).Exec(ctx)
+		assert.NoError(t, err)
+
+		_, err = db.NewInsert().Model(&auth.EmailLogin{
+			Email:      "first@email.com",
+			Password:   "password",
+			AuthUserID: user.ID,
+		}).Exec(ctx)
+		assert.NoError(t, err)
+
+		_, err = db.NewInsert().Model(&auth.EmailLogin{
+			Email:      "second@email.com",
+			Password:   "password",
+			AuthUserID: user.ID,
+		}).Exec(ctx)
+		assert.Error(t, err, "an auth user should have one email login")
+	})
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+var (
+	_ bun.BeforeAppendModelHook = (*Organization)(nil)
+	_ bun.BeforeAppendModelHook = (*Membership)(nil)
+	_ bun.BeforeAppendModelHook = (*Invitation)(nil)
+	_ database.IndexedModel     = (*Membership)(nil)
+	_ database.IndexedModel     = (*Invitation)(nil)
+)
+
+type MemberRole string
+
+const (
+	MemberRoleOwner  MemberRole = "OWNER"
+	MemberRoleAdmin  MemberRole = "ADMIN"
+	MemberRoleMember MemberRole = "MEMBER"
+)
+
+// Includes reports whether r grants at least the rights of other: an owner
+// includes admins, which include members.
+func (r MemberRole) Includes(other MemberRole) bool {
+	return r.rank() >= other.rank()
+}
+
+func (r MemberRole) rank() int {
+	switch r {
+	case MemberRoleOwner:
+		return 3
+	case MemberRoleAdmin:
+		return 2
+	case MemberRoleMember:
+		return 1
+	default:
+		return 0
+	}
+}
+
+type Organization struct {
+	bun.BaseModel `bun:"auth_organizations"`
+	ID            string    `bun:"id,pk,notnull,type:varchar(32)"`
+	Name          string    `bun:"name,notnull,type:varchar(128)"`
+	CreatedAt     time.Time `bun:"created_at,notnull"`
+	UpdatedAt     time.Time `bun:"updated_at,notnull"`
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (o *Organization) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		o.CreatedAt = time.Now()
+		o.UpdatedAt = time.Now()
+	case *bun.UpdateQuery:
+		o.UpdatedAt = time.Now()
+	}
+
+	return nil
+}
+
+type Membership struct {
+	bun.BaseModel  `bun:"auth_memberships"`
+	OrganizationID string     `bun:"organization_id,pk,notnull,type:varchar(32)"`
+	AuthUserID     string     `bun:"auth_user_id,pk,notnull,type:varchar(32)"`
+	Role           MemberRole `bun:"role,notnull,type:varchar(16)"`
+	CreatedAt      time.Time  `bun:"created_at,notnull"`
+	UpdatedAt      time.Time  `bun:"updated_at,notnull"`
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (m *Membership) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		m.CreatedAt = time.Now()
+		m.UpdatedAt = time.Now()
+	case *bun.UpdateQuery:
+		m.UpdatedAt = time.Now()
+	}
+
+	return nil
+}
+
+// Indexes implements database.IndexedModel.
+func (m *Membership) Indexes() []database.Index {
+	return []database.Index{
+		{Name: "auth_memberships_auth_user_id_idx", Columns: []string{"auth_user_id"}},
+	}
+}
+
+// Invitation invites an email address to join an organization. Only the
+// SHA-256 hash of the invitation token is stored.
+type Invitation struct {
+	bun.BaseModel  `bun:"auth_invitations"`
+	ID             string     `bun:"id,pk,notnull,type:varchar(32)"`
+	OrganizationID string     `bun:"organization_id,notnull,type:varchar(32)"`
+	Email          string     `bun:"email,notnull,type:varchar(320)"`
+	Role           MemberRole `bun:"role,notnull,type:varchar(16)"`
+	TokenHash      string     `bun:"token_hash,notnull,unique,type:varchar(64)"`
+	InvitedBy      string     `bun:"invited_by,notnull,type:varchar(32)"`
+	ExpiresAt      time.Time  `bun:"expires_at,notnull"`
+	AcceptedAt     *time.Time `bun:"accepted_at"`
+	CreatedAt      time.Time  `bun:"created_at,notnull"`
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (i *Invitation) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		i.CreatedAt = time.Now()
+	}
+
+	return nil
+}
+
+// Indexes implements database.IndexedModel.
+func (i *Invitation) Indexes() []database.Index {
+	return []database.Index{
+		{Name: "auth_invitations_organization_id_idx", Columns: []string{"organization_id"}},
+	}
+}
@@ -0,0 +1,435 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/uptrace/bun"
+)
+
+// DefaultInviteDuration is how long invitations last when
+// Config.InviteDuration is not set.
+const DefaultInviteDuration = 7 * 24 * time.Hour
+
+var (
+	ErrNotMember          = errors.New("not a member of organization")
+	ErrAlreadyMember      = errors.New("already a member of organization")
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrInvitationExpired  = errors.New("invitation expired")
+	ErrInvalidMemberRole  = errors.New("invalid member role")
+	ErrInsufficientRole   = errors.New("insufficient member role")
+	ErrLastOwner          = errors.New("organization must keep an owner")
+)
+
+// CreateOrganization creates an organization owned by dto.AuthUserID.
+func (s *Service) CreateOrganization(ctx context.Context, dto *CreateOrganizationRequest) (*OrganizationResponse, error) {
+
+	if err := s.ensureUser(ctx, dto.AuthUserID); err != nil {
+		return nil, err
+	}
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	org := Organization{
+		ID:   gonanoid.Must(32),
+		Name: dto.Name,
+	}
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+
+		if _, err := tx.NewInsert().Model(&org).Exec(ctx); err != nil {
+			return err
+		}
+
+		_, err := tx.NewInsert().
+			Model(&Membership{
+				OrganizationID: org.ID,
+				AuthUserID:     dto.AuthUserID,
+				Role:           MemberRoleOwner,
+			}).
+			Exec(ctx)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrganizationResponse{
+		ID:   org.ID,
+		Name: org.Name,
+		Role: MemberRoleOwner,
+	}, nil
+}
+
+// Organizations returns the organizations an auth user is a member of.
+func (s *Service) Organizations(ctx context.Context, authUserId string) ([]OrganizationResponse, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := []OrganizationResponse{}
+
+	err = db.NewSelect().
+		TableExpr("auth_organizations AS o").
+		Join("JOIN auth_memberships AS m ON m.organization_id = o.id").
+		ColumnExpr("o.id, o.name, m.role").
+		Where("m.auth_user_id = ?", authUserId).
+		Order("o.name").
+		Scan(ctx, &res)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Members returns the members of an organization.
+func (s *Service) Members(ctx context.Context, orgId string) ([]MemberResponse, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := []MemberResponse{}
+
+	err = db.NewSelect().
+		TableExpr("auth_memberships AS m").
+		Join("JOIN auth_users AS u ON u.id = m.auth_user_id").
+		ColumnExpr("m.auth_user_id, u.user_id, m.role, m.created_at").
+		Where("m.organization_id = ?", orgId).
+		Order("m.created_at").
+		Scan(ctx, &res)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Invite invites an email address to an organization. The plain invitation
+// token is only returned once and is meant to be delivered by email.
+// Returns ErrInsufficientRole if dto.Role is above the role of the inviter.
+func (s *Service) Invite(ctx context.Context, dto *InviteRequest) (*InviteResponse, error) {
+
+	if !validMemberRole(dto.Role) {
+		return nil, ErrInvalidMemberRole
+	}
+
+	inviter, err := s.membership(ctx, dto.OrganizationID, dto.InvitedBy)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Members may only invite with a role up to their own
+	if !inviter.Role.Includes(dto.Role) {
+		return nil, ErrInsufficientRole
+	}
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	token := gonanoid.Must(48)
+
+	invitation := Invitation{
+		ID:             gonanoid.Must(32),
+		OrganizationID: dto.OrganizationID,
+		Email:          dto.Email,
+		Role:           dto.Role,
+		TokenHash:      hashSecret(token),
+		InvitedBy:      dto.InvitedBy,
+		ExpiresAt:      time.Now().Add(s.inviteDuration()),
+	}
+
+	if _, err := db.NewInsert().Model(&invitation).Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return &InviteResponse{
+		ID:        invitation.ID,
+		Token:     token,
+		ExpiresAt: invitation.ExpiresAt,
+	}, nil
+}
+
+// AcceptInvitation adds dto.AuthUserID to the organization of the
+// invitation. The auth user must have an email login matching the invited
+// email address.
+func (s *Service) AcceptInvitation(ctx context.Context, dto *AcceptInvitationRequest) (*OrganizationResponse, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var invitation Invitation
+
+	err = db.NewSelect().
+		Model(&invitation).
+		Where("token_hash = ?", hashSecret(dto.Token)).
+		Where("accepted_at IS NULL").
+		Limit(1).
+		Scan(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvitationNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !time.Now().Before(invitation.ExpiresAt) {
+		return nil, ErrInvitationExpired
+	}
+
+	exists, err := db.NewSelect().
+		Model((*EmailLogin)(nil)).
+		Where("email = ?", invitation.Email).
+		Where("auth_user_id = ?", dto.AuthUserID).
+		Exists(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		return nil, ErrInvitationNotFound
+	}
+
+	var org Organization
+
+	if err := db.NewSelect().Model(&org).Where("id = ?", invitation.OrganizationID).Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+
+		_, err := tx.NewInsert().
+			Model(&Membership{
+				OrganizationID: invitation.OrganizationID,
+				AuthUserID:     dto.AuthUserID,
+				Role:           invitation.Role,
+			}).
+			Exec(ctx)
+
+		// The primary key rejects a second membership
+		if errors.Is(database.ClassifyError(err), database.ErrUniqueViolation) {
+			return ErrAlreadyMember
+		}
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*Invitation)(nil)).
+			Where("id = ?", invitation.ID).
+			Set("accepted_at = ?", time.Now()).
+			Exec(ctx)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrganizationResponse{
+		ID:   org.ID,
+		Name: org.Name,
+		Role: invitation.Role,
+	}, nil
+}
+
+// UpdateMemberRole changes the role of a member of an organization.
+// Returns ErrLastOwner if it would leave the organization without an owner.
+func (s *Service) UpdateMemberRole(ctx context.Context, orgId string, authUserId string, role MemberRole) error {
+
+	if !validMemberRole(role) {
+		return ErrInvalidMemberRole
+	}
+
+	if _, err := s.membership(ctx, orgId, authUserId); err != nil {
+		return err
+	}
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+
+		if err := lockOrganization(ctx, tx, orgId); err != nil {
+			return err
+		}
+
+		_, err := tx.NewUpdate().
+			Model(&Membership{Role: role}).
+			Column("role", "updated_at").
+			Where("organization_id = ?", orgId).
+			Where("auth_user_id = ?", authUserId).
+			Exec(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		return ensureOwner(ctx, tx, orgId)
+	})
+}
+
+// RemoveMember removes an auth user from an organization. Tokens with the
+// organization active stop verifying immediately. Returns ErrLastOwner if it
+// would leave the organization without an owner.
+func (s *Service) RemoveMember(ctx context.Context, orgId string, authUserId string) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+
+		if err := lockOrganization(ctx, tx, orgId); err != nil {
+			return err
+		}
+
+		res, err := tx.NewDelete().
+			Model((*Membership)(nil)).
+			Where("organization_id = ?", orgId).
+			Where("auth_user_id = ?", authUserId).
+			Exec(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+
+		if err != nil {
+			return err
+		}
+
+		if affected == 0 {
+			return ErrNotMember
+		}
+
+		return ensureOwner(ctx, tx, orgId)
+	})
+}
+
+// lockOrganization writes to the organization row so that concurrent
+// changes to its members are serialized, whatever the dialect.
+func lockOrganization(ctx context.Context, tx bun.Tx, orgId string) error {
+
+	_, err := tx.NewUpdate().
+		Model((*Organization)(nil)).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", orgId).
+		Exec(ctx)
+
+	return err
+}
+
+// ensureOwner returns ErrLastOwner if the organization has no owner left.
+func ensureOwner(ctx context.Context, tx bun.Tx, orgId string) error {
+
+	owners, err := tx.NewSelect().
+		Model((*Membership)(nil)).
+		Where("organization_id = ?", orgId).
+		Where("role = ?", MemberRoleOwner).
+		Count(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if owners == 0 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
+
+// SwitchOrganization issues new tokens with orgId as the active
+// organization. To keep the active organization when refreshing, call it
+// with the OrganizationID of the verified refresh token instead of
+// CreateTokens.
+func (s *Service) SwitchOrganization(ctx context.Context, authUserId string, orgId string) (*TokenResponse, error) {
+
+	if _, err := s.membership(ctx, orgId, authUserId); err != nil {
+		return nil, err
+	}
+
+	return s.createTokens(ctx, authUserId, orgId)
+}
+
+func (s *Service) membership(ctx context.Context, orgId string, authUserId string) (*Membership, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var membership Membership
+
+	err = db.NewSelect().
+		Model(&membership).
+		Where("organization_id = ?", orgId).
+		Where("auth_user_id = ?", authUserId).
+		Limit(1).
+		Scan(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotMember
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &membership, nil
+}
+
+func validMemberRole(role MemberRole) bool {
+	switch role {
+	case MemberRoleOwner, MemberRoleAdmin, MemberRoleMember:
+		return true
+	}
+
+	return false
+}
+
+func (s *Service) inviteDuration() time.Duration {
+	if s.cfg.InviteDuration <= 0 {
+		return DefaultInviteDuration
+	}
+
+	return s.cfg.InviteDuration
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/joelywz/mo/database"
+	"github.com/labstack/echo/v4"
+)
+
+type RequestInfoKey struct{}
+
+// RequestInfo describes the client of the current request. It is attached
+// to audit events.
+type RequestInfo struct {
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+// WithRequestInfo returns a new context carrying info.
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, RequestInfoKey{}, info)
+}
+
+// RequestInfoFromContext retrieves the request info from the context. The
+// zero value is returned if there is none.
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(RequestInfoKey{}).(RequestInfo)
+	return info
+}
+
+// RequestInfoMiddleware captures the client IP, user agent and request ID
+// into the request context. The request ID is taken from the X-Request-ID
+// header of the request or, when echo's RequestID middleware runs first,
+// the response. The request ID is also given to database.WithRequestID to
+// tag the queries of the request.
+func RequestInfoMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			requestID := req.Header.Get(echo.HeaderXRequestID)
+
+			if requestID == "" {
+				requestID = c.Response().Header().Get(echo.HeaderXRequestID)
+			}
+
+			ctx := WithRequestInfo(req.Context(), RequestInfo{
+				IP:        c.RealIP(),
+				UserAgent: req.UserAgent(),
+				RequestID: requestID,
+			})
+
+			if requestID != "" {
+				ctx = database.WithRequestID(ctx, requestID)
+			}
+
+			c.SetRequest(req.WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+var (
+	_ bun.BeforeAppendModelHook = (*UserRole)(nil)
+	_ bun.BeforeAppendModelHook = (*UserScope)(nil)
+)
+
+// UserRole grants a role to an auth user. Roles are embedded in access
+// tokens as the "roles" claim.
+type UserRole struct {
+	bun.BaseModel `bun:"auth_user_roles"`
+	AuthUserID    string    `bun:"auth_user_id,pk,notnull,type:varchar(32)"`
+	Role          string    `bun:"role,pk,notnull,type:varchar(64)"`
+	CreatedAt     time.Time `bun:"created_at,notnull"`
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (r *UserRole) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		r.CreatedAt = time.Now()
+	}
+
+	return nil
+}
+
+// UserScope grants a permission scope to an auth user. Scopes are embedded
+// in access tokens as the space separated "scope" claim.
+type UserScope struct {
+	bun.BaseModel `bun:"auth_user_scopes"`
+	AuthUserID    string    `bun:"auth_user_id,pk,notnull,type:varchar(32)"`
+	Scope         string    `bun:"scope,pk,notnull,type:varchar(128)"`
+	CreatedAt     time.Time `bun:"created_at,notnull"`
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (s *UserScope) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		s.CreatedAt = time.Now()
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/joelywz/mo/database"
+)
+
+// GrantRole grants a role to an auth user. Granting a role twice is a no-op.
+// Tokens issued before the grant do not carry the role.
+func (s *Service) GrantRole(ctx context.Context, authUserId string, role string) error {
+
+	if err := s.ensureUser(ctx, authUserId); err != nil {
+		return err
+	}
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewInsert().
+		Model(&UserRole{AuthUserID: authUserId, Role: role}).
+		Ignore().
+		Exec(ctx)
+
+	return err
+}
+
+// RevokeRole removes a role from an auth user.
+func (s *Service) RevokeRole(ctx context.Context, authUserId string, role string) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewDelete().
+		Model((*UserRole)(nil)).
+		Where("auth_user_id = ?", authUserId).
+		Where("role = ?", role).
+		Exec(ctx)
+
+	return err
+}
+
+// Roles returns the roles granted to an auth user.
+func (s *Service) Roles(ctx context.Context, authUserId string) ([]string, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	roles := []string{}
+
+	err = db.NewSelect().
+		Model((*UserRole)(nil)).
+		Column("role").
+		Where("auth_user_id = ?", authUserId).
+		Order("role").
+		Scan(ctx, &roles)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// GrantScope grants a scope to an auth user. Granting a scope twice is a
+// no-op. Tokens issued before the grant do not carry the scope.
+func (s *Service) GrantScope(ctx context.Context, authUserId string, scope string) error {
+
+	if err := s.ensureUser(ctx, authUserId); err != nil {
+		return err
+	}
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewInsert().
+		Model(&UserScope{AuthUserID: authUserId, Scope: scope}).
+		Ignore().
+		Exec(ctx)
+
+	return err
+}
+
+// RevokeScope removes a scope from an auth user.
+func (s *Service) RevokeScope(ctx context.Context, authUserId string, scope string) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewDelete().
+		Model((*UserScope)(nil)).
+		Where("auth_user_id = ?", authUserId).
+		Where("scope = ?", scope).
+		Exec(ctx)
+
+	return err
+}
+
+// Scopes returns the scopes granted to an auth user.
+func (s *Service) Scopes(ctx context.Context, authUserId string) ([]string, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []string{}
+
+	err = db.NewSelect().
+		Model((*UserScope)(nil)).
+		Column("scope").
+		Where("auth_user_id = ?", authUserId).
+		Order("scope").
+		Scan(ctx, &scopes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scopes, nil
+}
+
+func (s *Service) ensureUser(ctx context.Context, authUserId string) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	exists, err := db.NewSelect().
+		Model((*User)(nil)).
+		Where("id = ?", authUserId).
+		Exists(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrNotFound
+	}
+
+	return nil
+}
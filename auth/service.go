@@ -2,7 +2,9 @@ package auth
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -19,13 +21,35 @@ var (
 )
 
 type Service struct {
-	cfg *Config
+	cfg   *Config
+	audit AuditSink
+	hooks Hooks
 }
 
-func NewService(cfg *Config) *Service {
-	return &Service{
+type Option func(s *Service)
+
+// WithAuditSink emits security relevant events to sink.
+func WithAuditSink(sink AuditSink) Option {
+	return func(s *Service) {
+		s.audit = sink
+	}
+}
+
+func NewService(cfg *Config, opts ...Option) *Service {
+	s := &Service{
 		cfg: cfg,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Hooks returns the registry of hooks fired by the service.
+func (s *Service) Hooks() *Hooks {
+	return &s.hooks
 }
 
 func (s *Service) Login(ctx context.Context, dto *LoginRequest) (*LoginResponse, error) {
@@ -47,6 +71,12 @@ func (s *Service) Login(ctx context.Context, dto *LoginRequest) (*LoginResponse,
 	}
 
 	if !exists {
+		s.record(ctx, AuditEvent{
+			Type:   AuditLoginFailed,
+			Email:  &dto.Email,
+			Reason: "unknown email",
+		})
+
 		return nil, ErrInvalidCredentials
 	}
 
@@ -69,6 +99,13 @@ func (s *Service) Login(ctx context.Context, dto *LoginRequest) (*LoginResponse,
 	}
 
 	if !match {
+		s.record(ctx, AuditEvent{
+			Type:       AuditLoginFailed,
+			AuthUserID: &emailLogin.AuthUserID,
+			Email:      &dto.Email,
+			Reason:     "wrong password",
+		})
+
 		return nil, ErrInvalidCredentials
 	}
 
@@ -81,6 +118,12 @@ func (s *Service) Login(ctx context.Context, dto *LoginRequest) (*LoginResponse,
 		return nil, err
 	}
 
+	s.record(ctx, AuditEvent{
+		Type:       AuditLoginSucceeded,
+		AuthUserID: &emailLogin.AuthUserID,
+		Email:      &dto.Email,
+	})
+
 	return &LoginResponse{
 		AuthUserID: emailLogin.AuthUserID,
 		UserID:     user.UserID,
@@ -89,66 +132,173 @@ func (s *Service) Login(ctx context.Context, dto *LoginRequest) (*LoginResponse,
 
 func (s *Service) Register(ctx context.Context, dto *RegisterRequest) (*RegisterResponse, error) {
 
-	db, err := database.FromContext(ctx)
+	// Create new email password
+	argon := argon2.DefaultConfig()
+
+	encoded, err := argon.HashEncoded([]byte(dto.Password))
 
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if email exist in database
-	exists, err := db.NewSelect().
-		Model((*EmailLogin)(nil)).
-		Where("email = ?", dto.Email).
-		Exists(ctx)
+	user := User{
+		ID:      gonanoid.Must(32),
+		Version: gonanoid.Must(32),
+		UserID:  nil,
+	}
+
+	event := RegisteredEvent{
+		AuthUserID: user.ID,
+		Email:      dto.Email,
+	}
+
+	err = database.RunInTx(ctx, nil, func(ctx context.Context) error {
+
+		tx, err := database.FromContext(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		// Create new auth user
+		if _, err := tx.NewInsert().Model(&user).Exec(ctx); err != nil {
+			return err
+		}
+
+		emailLogin := EmailLogin{
+			Email:      dto.Email,
+			Password:   string(encoded),
+			AuthUserID: user.ID,
+		}
+
+		// Save email and password, the primary key rejects an existing email
+		if _, err := tx.NewInsert().Model(&emailLogin).Exec(ctx); err != nil {
+			if errors.Is(database.ClassifyError(err), database.ErrUniqueViolation) {
+				return ErrEmailExists
+			}
+
+			return err
+		}
+
+		return s.hooks.Registered.runBefore(ctx, event)
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	if exists {
-		return nil, ErrEmailExists
+	s.record(ctx, AuditEvent{
+		Type:       AuditRegistered,
+		AuthUserID: &user.ID,
+		Email:      &dto.Email,
+	})
+
+	s.hooks.Registered.scheduleAfter(ctx, event)
+
+	return &RegisterResponse{
+		AuthUserID: user.ID,
+		UserID:     user.UserID,
+	}, nil
+
+}
+
+// ChangePassword replaces the password of an email login. Tokens issued
+// before the change stop verifying, as with Revoke.
+func (s *Service) ChangePassword(ctx context.Context, dto *ChangePasswordRequest) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
 	}
 
-	// Create new auth user
-	user := User{
-		ID:      gonanoid.Must(32),
-		Version: gonanoid.Must(32),
-		UserID:  nil,
+	var emailLogin EmailLogin
+
+	err = db.NewSelect().
+		Model(&emailLogin).
+		Where("email = ?", dto.Email).
+		Scan(ctx)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		s.record(ctx, AuditEvent{
+			Type:   AuditPasswordChangeFailed,
+			Email:  &dto.Email,
+			Reason: "unknown email",
+		})
+
+		return ErrInvalidCredentials
 	}
 
-	_, err = db.NewInsert().
-		Model(&user).
-		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	match, err := argon2.VerifyEncoded([]byte(dto.OldPassword), []byte(emailLogin.Password))
 
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if !match {
+		s.record(ctx, AuditEvent{
+			Type:       AuditPasswordChangeFailed,
+			AuthUserID: &emailLogin.AuthUserID,
+			Email:      &dto.Email,
+			Reason:     "wrong password",
+		})
+
+		return ErrInvalidCredentials
 	}
 
-	// Create new email password
 	argon := argon2.DefaultConfig()
 
-	encoded, err := argon.HashEncoded([]byte(dto.Password))
+	encoded, err := argon.HashEncoded([]byte(dto.NewPassword))
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	emailLogin := EmailLogin{
-		Email:      dto.Email,
-		Password:   string(encoded),
-		AuthUserID: user.ID,
-	}
+	emailLogin.Password = string(encoded)
 
-	// Save email and password
-	if _, err := db.NewInsert().Model(&emailLogin).Exec(ctx); err != nil {
-		return nil, err
+	err = database.RunInTx(ctx, nil, func(ctx context.Context) error {
+
+		tx, err := database.FromContext(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewUpdate().
+			Model(&emailLogin).
+			Column("password", "updated_at").
+			WherePK().
+			Exec(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		// Update version of auth user so that issued tokens stop verifying
+		_, err = tx.NewUpdate().
+			Model((*User)(nil)).
+			Where("id = ?", emailLogin.AuthUserID).
+			Set("version = ?", gonanoid.Must(32)).
+			Exec(ctx)
+
+		return err
+	})
+
+	if err != nil {
+		return err
 	}
 
-	return &RegisterResponse{
-		AuthUserID: user.ID,
-		UserID:     user.UserID,
-	}, nil
+	s.record(ctx, AuditEvent{
+		Type:       AuditPasswordChanged,
+		AuthUserID: &emailLogin.AuthUserID,
+		Email:      &dto.Email,
+	})
 
+	return nil
 }
 
 func (s *Service) Link(ctx context.Context, dto *LinkRequest) error {
@@ -171,16 +321,43 @@ func (s *Service) Link(ctx context.Context, dto *LinkRequest) error {
 		return ErrNotFound
 	}
 
-	_, err = db.NewUpdate().
-		Model((*User)(nil)).
-		Where("id = ?", dto.AuthUserID).
-		Set("user_id = ?", dto.UserID).
-		Exec(ctx)
+	event := LinkedEvent{
+		AuthUserID: dto.AuthUserID,
+		UserID:     dto.UserID,
+	}
+
+	err = database.RunInTx(ctx, nil, func(ctx context.Context) error {
+
+		tx, err := database.FromContext(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*User)(nil)).
+			Where("id = ?", dto.AuthUserID).
+			Set("user_id = ?", dto.UserID).
+			Exec(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		return s.hooks.Linked.runBefore(ctx, event)
+	})
 
 	if err != nil {
 		return err
 	}
 
+	s.record(ctx, AuditEvent{
+		Type:       AuditLinked,
+		AuthUserID: &dto.AuthUserID,
+	})
+
+	s.hooks.Linked.scheduleAfter(ctx, event)
+
 	return nil
 }
 
@@ -206,17 +383,43 @@ func (s *Service) Revoke(ctx context.Context, authUserId string) error {
 		return ErrNotFound
 	}
 
-	// Update version of auth user
-	_, err = db.NewUpdate().
-		Model((*User)(nil)).
-		Where("id = ?", authUserId).
-		Set("version = ?", gonanoid.Must(32)).
-		Exec(ctx)
+	event := RevokedEvent{
+		AuthUserID: authUserId,
+	}
+
+	err = database.RunInTx(ctx, nil, func(ctx context.Context) error {
+
+		tx, err := database.FromContext(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		// Update version of auth user
+		_, err = tx.NewUpdate().
+			Model((*User)(nil)).
+			Where("id = ?", authUserId).
+			Set("version = ?", gonanoid.Must(32)).
+			Exec(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		return s.hooks.Revoked.runBefore(ctx, event)
+	})
 
 	if err != nil {
 		return err
 	}
 
+	s.record(ctx, AuditEvent{
+		Type:       AuditRevoked,
+		AuthUserID: &authUserId,
+	})
+
+	s.hooks.Revoked.scheduleAfter(ctx, event)
+
 	return nil
 }
 
@@ -263,13 +466,37 @@ func (s *Service) Verify(ctx context.Context, token string, tokenType TokenType)
 		return nil, ErrBadToken
 	}
 
-	return &VerifyResponse{
+	res := &VerifyResponse{
 		AuthUserID: user.ID,
 		UserID:     user.UserID,
-	}, nil
+		Scopes:     strings.Fields(claims.Scope),
+		Roles:      claims.Roles,
+	}
+
+	// Check the user is still a member of the active organization
+	if claims.OrgID != "" {
+		membership, err := s.membership(ctx, claims.OrgID, user.ID)
+
+		if errors.Is(err, ErrNotMember) {
+			return nil, ErrBadToken
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		res.OrganizationID = &membership.OrganizationID
+		res.OrganizationRole = membership.Role
+	}
+
+	return res, nil
 }
 
 func (s *Service) CreateTokens(ctx context.Context, authUserId string) (*TokenResponse, error) {
+	return s.createTokens(ctx, authUserId, "")
+}
+
+func (s *Service) createTokens(ctx context.Context, authUserId string, orgId string) (*TokenResponse, error) {
 
 	db, err := database.FromContext(ctx)
 
@@ -285,7 +512,24 @@ func (s *Service) CreateTokens(ctx context.Context, authUserId string) (*TokenRe
 		return nil, err
 	}
 
-	refreshToken, refreshClaims, err := s.createJwt(authUserId, user.Version, TokenTypeRefresh)
+	roles, err := s.Roles(ctx, authUserId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, err := s.Scopes(ctx, authUserId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshClaims, err := s.createJwt(TokenClaims{
+		ID:      authUserId,
+		Version: user.Version,
+		Type:    TokenTypeRefresh,
+		OrgID:   orgId,
+	})
 
 	if err != nil {
 		return nil, err
@@ -293,7 +537,16 @@ func (s *Service) CreateTokens(ctx context.Context, authUserId string) (*TokenRe
 
 	refreshExp, _ := refreshClaims.GetExpirationTime()
 
-	accessToken, accessClaims, err := s.createJwt(authUserId, user.Version, TokenTypeAccess)
+	// Only access tokens carry authorization claims, refreshing picks up
+	// changes to roles and scopes.
+	accessToken, accessClaims, err := s.createJwt(TokenClaims{
+		ID:      authUserId,
+		Version: user.Version,
+		Type:    TokenTypeAccess,
+		Scope:   strings.Join(scopes, " "),
+		Roles:   roles,
+		OrgID:   orgId,
+	})
 
 	if err != nil {
 		return nil, err
@@ -309,15 +562,9 @@ func (s *Service) CreateTokens(ctx context.Context, authUserId string) (*TokenRe
 	}, nil
 }
 
-func (s *Service) createJwt(authUserId string, version string, tokenType TokenType) (string, jwt.Claims, error) {
-
-	claims := TokenClaims{
-		ID:      authUserId,
-		Version: version,
-		Type:    tokenType,
-	}
+func (s *Service) createJwt(claims TokenClaims) (string, jwt.Claims, error) {
 
-	switch tokenType {
+	switch claims.Type {
 	case TokenTypeAccess:
 		claims.ExpiresAt = jwt.NewNumericDate(
 			time.Now().Add(s.cfg.AccessDuration),
@@ -2,95 +2,57 @@ package auth_test
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/joelywz/mo/auth"
 	"github.com/joelywz/mo/database"
-	"github.com/ory/dockertest/v3"
-	"github.com/ory/dockertest/v3/docker"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
 )
 
 var db *bun.DB
 
 func TestMain(m *testing.M) {
-	pool, err := dockertest.NewPool("")
+	var (
+		purge func() error
+		err   error
+	)
 
-	if err != nil {
-		log.Fatalf("Could not construct pool: %s", err)
-	}
-
-	err = pool.Client.Ping()
-
-	if err != nil {
-		log.Fatalf("Could not connect to Docker: %s", err)
-	}
-
-	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
-		Repository: "mysql",
-		Tag:        "latest",
-		Env: []string{
-			"MYSQL_ROOT_PASSWORD=root",
-			"MYSQL_DATABASE=mo_auth",
-			"MYSQL_DATABASE=mo_auth",
-		},
-	}, func(config *docker.HostConfig) {
-		config.AutoRemove = true
-		config.RestartPolicy = docker.RestartPolicy{Name: "no"}
-	})
+	db, purge, err = dbtest.Open("mo_auth")
 
 	if err != nil {
-		log.Fatalf("Could not start resource: %s", err)
-	}
-
-	if err := resource.Expire(60); err != nil {
-		log.Fatalf("Could not set expiry for resource: %s", err)
+		log.Fatalf("Could not start database: %s", err)
 	}
 
-	if err := pool.Retry(func() error {
-		manager, err := database.NewMySQLManager(&database.Config{
-			Dialect: "mysql",
-			Host:    "localhost",
-			Port:    resource.GetPort("3306/tcp"),
-			User:    "root",
-			Pass:    "root",
-			Name:    "mo_auth",
-		})
-
-		if err != nil {
-			return err
-		}
-
-		db, err = manager.Bun()
-
-		if err != nil {
-			return err
-		}
+	// Migrate database
+	migrations := migrate.NewMigrations()
+	auth.RegisterMigrations(migrations)
 
-		return db.Ping()
-	}); err != nil {
-		log.Fatalf("Could not connect to Docker: %s", err)
-	}
+	migrator := migrate.NewMigrator(db, migrations)
 
-	// Migrate database
-	if _, err := db.NewCreateTable().Model((*auth.User)(nil)).Exec(context.Background()); err != nil {
-		log.Fatalf("Could not create table: %s", err)
+	if err := migrator.Init(context.Background()); err != nil {
+		log.Fatalf("Could not init migrations: %s", err)
 	}
 
-	if _, err := db.NewCreateTable().Model((*auth.EmailLogin)(nil)).Exec(context.Background()); err != nil {
-		log.Fatalf("Could not create table: %s", err)
-
+	if _, err := migrator.Migrate(context.Background()); err != nil {
+		log.Fatalf("Could not migrate: %s", err)
 	}
 
 	log.Println("Ready for testing")
 
 	code := m.Run()
 
-	if err := pool.Purge(resource); err != nil {
+	if err := purge(); err != nil {
 		log.Fatalf("Could not purge resource: %s", err)
 	}
 
@@ -101,8 +63,8 @@ func TestService(t *testing.T) {
 
 	authService := auth.NewService(&auth.Config{
 		Secret:          "secret",
-		RefreshDuration: 2 * time.Second,
-		AccessDuration:  4 * time.Second,
+		RefreshDuration: 4 * time.Second,
+		AccessDuration:  2 * time.Second,
 	})
 
 	email := "email@email.com"
@@ -188,3 +150,604 @@ func TestService(t *testing.T) {
 	assert.ErrorIs(t, err, auth.ErrBadToken, "verify refresh token should return ErrBadToken after revocation")
 
 }
+
+func TestAPIKey(t *testing.T) {
+
+	authService := auth.NewService(&auth.Config{
+		Secret:         "secret",
+		AccessDuration: 10 * time.Minute,
+	})
+
+	ctx := context.Background()
+	ctx = database.WithContext(ctx, db)
+
+	registerRes, err := authService.Register(ctx, &auth.RegisterRequest{
+		Email:    "apikey@email.com",
+		Password: "1234567890",
+	})
+
+	assert.NoError(t, err, "register should not return error")
+
+	// Creation
+	created, err := authService.CreateAPIKey(ctx, &auth.CreateAPIKeyRequest{
+		AuthUserID: registerRes.AuthUserID,
+		Name:       "cli",
+		Scopes:     []string{"orders:read", "orders:write"},
+	})
+
+	assert.NoError(t, err, "create api key should not return error")
+	assert.True(t, authService.IsAPIKey(created.Key), "api key should carry the configured prefix")
+
+	_, err = authService.CreateAPIKey(ctx, &auth.CreateAPIKeyRequest{
+		AuthUserID: "missing",
+		Name:       "cli",
+	})
+
+	assert.ErrorIs(t, err, auth.ErrNotFound, "create api key should return ErrNotFound for unknown user")
+
+	// Verification
+	verifyRes, err := authService.VerifyAPIKey(ctx, created.Key)
+
+	assert.NoError(t, err, "verify api key should not return error")
+	assert.Equal(t, registerRes.AuthUserID, verifyRes.AuthUserID)
+	assert.Equal(t, []string{"orders:read", "orders:write"}, verifyRes.Scopes)
+
+	// Change the last character of the secret to a different one
+	wrong := "x"
+
+	if strings.HasSuffix(created.Key, wrong) {
+		wrong = "y"
+	}
+
+	_, err = authService.VerifyAPIKey(ctx, created.Key[:len(created.Key)-1]+wrong)
+
+	assert.ErrorIs(t, err, auth.ErrBadToken, "verify api key should return ErrBadToken for wrong secret")
+
+	keys, err := authService.ListAPIKeys(ctx, registerRes.AuthUserID)
+
+	assert.NoError(t, err, "list api keys should not return error")
+	assert.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].LastUsedAt, "verify should track last usage")
+
+	// Middleware
+	e := echo.New()
+	e.Use(database.GlobalMiddleware(db), auth.Middleware(authService))
+
+	e.GET("/", func(c echo.Context) error {
+		res, err := auth.FromContext(c.Request().Context())
+		assert.NoError(t, err)
+
+		return c.String(http.StatusOK, res.AuthUserID)
+	})
+
+	request := func(e *echo.Echo, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+key)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := request(e, created.Key)
+
+	assert.Equal(t, http.StatusOK, rec.Code, "valid api key should be accepted")
+	assert.Equal(t, registerRes.AuthUserID, rec.Body.String())
+
+	rec = request(e, created.Key[:len(created.Key)-1]+wrong)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "wrong api key should return 401")
+
+	// A database failure is not a bad token
+	closed, purge, err := dbtest.SQLite("mo_auth_closed")
+
+	assert.NoError(t, err)
+	assert.NoError(t, purge())
+
+	broken := echo.New()
+	broken.Use(database.GlobalMiddleware(closed), auth.Middleware(authService))
+	broken.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	rec = request(broken, created.Key)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code, "database failure should not return 401")
+
+	// Expiry
+	expiry := time.Now().Add(-time.Second)
+
+	expired, err := authService.CreateAPIKey(ctx, &auth.CreateAPIKeyRequest{
+		AuthUserID: registerRes.AuthUserID,
+		Name:       "expired",
+		ExpiresAt:  &expiry,
+	})
+
+	assert.NoError(t, err, "create api key should not return error")
+
+	_, err = authService.VerifyAPIKey(ctx, expired.Key)
+
+	assert.ErrorIs(t, err, auth.ErrBadToken, "verify api key should return ErrBadToken after expiry")
+
+	// Revocation
+	err = authService.RevokeAPIKey(ctx, registerRes.AuthUserID, created.ID)
+
+	assert.NoError(t, err, "revoke api key should not return error")
+
+	err = authService.RevokeAPIKey(ctx, registerRes.AuthUserID, created.ID)
+
+	assert.ErrorIs(t, err, auth.ErrAPIKeyNotFound, "revoke api key twice should return ErrAPIKeyNotFound")
+
+	_, err = authService.VerifyAPIKey(ctx, created.Key)
+
+	assert.ErrorIs(t, err, auth.ErrBadToken, "verify api key should return ErrBadToken after revocation")
+}
+
+func TestRolesAndScopes(t *testing.T) {
+
+	authService := auth.NewService(&auth.Config{
+		Secret:          "secret",
+		AccessDuration:  10 * time.Minute,
+		RefreshDuration: 10 * time.Minute,
+	})
+
+	ctx := context.Background()
+	ctx = database.WithContext(ctx, db)
+
+	registerRes, err := authService.Register(ctx, &auth.RegisterRequest{
+		Email:    "roles@email.com",
+		Password: "1234567890",
+	})
+
+	assert.NoError(t, err, "register should not return error")
+
+	// Grants
+	assert.NoError(t, authService.GrantRole(ctx, registerRes.AuthUserID, "admin"))
+	assert.NoError(t, authService.GrantRole(ctx, registerRes.AuthUserID, "admin"), "granting a role twice should not return error")
+	assert.NoError(t, authService.GrantScope(ctx, registerRes.AuthUserID, "orders:read"))
+	assert.ErrorIs(t, authService.GrantRole(ctx, "missing", "admin"), auth.ErrNotFound)
+
+	// Claims
+	tokens, err := authService.CreateTokens(ctx, registerRes.AuthUserID)
+
+	assert.NoError(t, err, "create tokens should not return error")
+
+	verifyRes, err := authService.Verify(ctx, tokens.AccessToken, auth.TokenTypeAccess)
+
+	assert.NoError(t, err, "verify access token should not return error")
+	assert.Equal(t, []string{"admin"}, verifyRes.Roles)
+	assert.Equal(t, []string{"orders:read"}, verifyRes.Scopes)
+
+	// Enforcement
+	e := echo.New()
+	e.Use(database.GlobalMiddleware(db), auth.Middleware(authService))
+
+	ok := func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}
+
+	e.GET("/read", ok, auth.RequireScope("orders:read"))
+	e.GET("/write", ok, auth.RequireScope("orders:write"))
+	e.GET("/admin", ok, auth.RequireRole("admin"))
+
+	cases := map[string]int{
+		"/read":  http.StatusNoContent,
+		"/write": http.StatusForbidden,
+		"/admin": http.StatusNoContent,
+	}
+
+	for path, status := range cases {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+tokens.AccessToken)
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, status, rec.Code, path)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/read", nil)
+	rec := httptest.NewRecorder()
+
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "missing token should return 401")
+
+	// Revocation of a role only applies to new tokens
+	assert.NoError(t, authService.RevokeRole(ctx, registerRes.AuthUserID, "admin"))
+
+	tokens, err = authService.CreateTokens(ctx, registerRes.AuthUserID)
+
+	assert.NoError(t, err, "create tokens should not return error")
+
+	verifyRes, err = authService.Verify(ctx, tokens.AccessToken, auth.TokenTypeAccess)
+
+	assert.NoError(t, err, "verify access token should not return error")
+	assert.Empty(t, verifyRes.Roles)
+}
+
+func TestOrganizations(t *testing.T) {
+
+	authService := auth.NewService(&auth.Config{
+		Secret:          "secret",
+		AccessDuration:  10 * time.Minute,
+		RefreshDuration: 10 * time.Minute,
+		InviteDuration:  time.Hour,
+	})
+
+	ctx := context.Background()
+	ctx = database.WithContext(ctx, db)
+
+	owner, err := authService.Register(ctx, &auth.RegisterRequest{
+		Email:    "owner@email.com",
+		Password: "1234567890",
+	})
+
+	assert.NoError(t, err, "register should not return error")
+
+	member, err := authService.Register(ctx, &auth.RegisterRequest{
+		Email:    "member@email.com",
+		Password: "1234567890",
+	})
+
+	assert.NoError(t, err, "register should not return error")
+
+	org, err := authService.CreateOrganization(ctx, &auth.CreateOrganizationRequest{
+		AuthUserID: owner.AuthUserID,
+		Name:       "Acme",
+	})
+
+	assert.NoError(t, err, "create organization should not return error")
+	assert.Equal(t, auth.MemberRoleOwner, org.Role)
+
+	// Switching to an organization without membership
+	_, err = authService.SwitchOrganization(ctx, member.AuthUserID, org.ID)
+
+	assert.ErrorIs(t, err, auth.ErrNotMember, "switch organization should return ErrNotMember")
+
+	// Invitation
+	invite, err := authService.Invite(ctx, &auth.InviteRequest{
+		OrganizationID: org.ID,
+		Email:          "member@email.com",
+		Role:           auth.MemberRoleMember,
+		InvitedBy:      owner.AuthUserID,
+	})
+
+	assert.NoError(t, err, "invite should not return error")
+
+	_, err = authService.AcceptInvitation(ctx, &auth.AcceptInvitationRequest{
+		Token:      invite.Token,
+		AuthUserID: owner.AuthUserID,
+	})
+
+	assert.ErrorIs(t, err, auth.ErrInvitationNotFound, "accept invitation should only accept the invited email")
+
+	_, err = authService.AcceptInvitation(ctx, &auth.AcceptInvitationRequest{
+		Token:      invite.Token,
+		AuthUserID: member.AuthUserID,
+	})
+
+	assert.NoError(t, err, "accept invitation should not return error")
+
+	_, err = authService.AcceptInvitation(ctx, &auth.AcceptInvitationRequest{
+		Token:      invite.Token,
+		AuthUserID: member.AuthUserID,
+	})
+
+	assert.ErrorIs(t, err, auth.ErrInvitationNotFound, "accept invitation twice should return ErrInvitationNotFound")
+
+	members, err := authService.Members(ctx, org.ID)
+
+	assert.NoError(t, err, "members should not return error")
+	assert.Len(t, members, 2)
+
+	orgs, err := authService.Organizations(ctx, member.AuthUserID)
+
+	assert.NoError(t, err, "organizations should not return error")
+	assert.Len(t, orgs, 1)
+
+	// Active organization
+	tokens, err := authService.SwitchOrganization(ctx, member.AuthUserID, org.ID)
+
+	assert.NoError(t, err, "switch organization should not return error")
+
+	verifyRes, err := authService.Verify(ctx, tokens.AccessToken, auth.TokenTypeAccess)
+
+	assert.NoError(t, err, "verify access token should not return error")
+
+	if assert.NotNil(t, verifyRes.OrganizationID) {
+		assert.Equal(t, org.ID, *verifyRes.OrganizationID)
+	}
+
+	assert.Equal(t, auth.MemberRoleMember, verifyRes.OrganizationRole)
+
+	// Members cannot invite above their own role
+	_, err = authService.Invite(ctx, &auth.InviteRequest{
+		OrganizationID: org.ID,
+		Email:          "takeover@email.com",
+		Role:           auth.MemberRoleOwner,
+		InvitedBy:      member.AuthUserID,
+	})
+
+	assert.ErrorIs(t, err, auth.ErrInsufficientRole, "invite should return ErrInsufficientRole above the inviter role")
+
+	_, err = authService.Invite(ctx, &auth.InviteRequest{
+		OrganizationID: org.ID,
+		Email:          "colleague@email.com",
+		Role:           auth.MemberRoleMember,
+		InvitedBy:      member.AuthUserID,
+	})
+
+	assert.NoError(t, err, "invite should not return error up to the inviter role")
+
+	// Invitations last a week unless configured
+	defaultInvite, err := auth.NewService(&auth.Config{Secret: "secret"}).Invite(ctx, &auth.InviteRequest{
+		OrganizationID: org.ID,
+		Email:          "later@email.com",
+		Role:           auth.MemberRoleMember,
+		InvitedBy:      owner.AuthUserID,
+	})
+
+	assert.NoError(t, err, "invite should not return error")
+	assert.WithinDuration(t, time.Now().Add(auth.DefaultInviteDuration), defaultInvite.ExpiresAt, time.Minute)
+
+	// The organization keeps an owner
+	roleOf := func(authUserId string) auth.MemberRole {
+		members, err := authService.Members(ctx, org.ID)
+
+		assert.NoError(t, err, "members should not return error")
+
+		for _, m := range members {
+			if m.AuthUserID == authUserId {
+				return m.Role
+			}
+		}
+
+		return ""
+	}
+
+	err = authService.UpdateMemberRole(ctx, org.ID, owner.AuthUserID, auth.MemberRoleAdmin)
+
+	assert.ErrorIs(t, err, auth.ErrLastOwner, "demoting the last owner should return ErrLastOwner")
+	assert.Equal(t, auth.MemberRoleOwner, roleOf(owner.AuthUserID), "failed demotion should be rolled back")
+
+	err = authService.RemoveMember(ctx, org.ID, owner.AuthUserID)
+
+	assert.ErrorIs(t, err, auth.ErrLastOwner, "removing the last owner should return ErrLastOwner")
+	assert.Equal(t, auth.MemberRoleOwner, roleOf(owner.AuthUserID), "failed removal should be rolled back")
+
+	assert.NoError(t, authService.UpdateMemberRole(ctx, org.ID, member.AuthUserID, auth.MemberRoleOwner))
+	assert.NoError(t, authService.UpdateMemberRole(ctx, org.ID, owner.AuthUserID, auth.MemberRoleAdmin), "demoting an owner should succeed with another owner")
+	assert.NoError(t, authService.UpdateMemberRole(ctx, org.ID, owner.AuthUserID, auth.MemberRoleOwner))
+	assert.NoError(t, authService.UpdateMemberRole(ctx, org.ID, member.AuthUserID, auth.MemberRoleMember))
+
+	// Removal invalidates tokens for the organization
+	err = authService.RemoveMember(ctx, org.ID, member.AuthUserID)
+
+	assert.NoError(t, err, "remove member should not return error")
+
+	_, err = authService.Verify(ctx, tokens.AccessToken, auth.TokenTypeAccess)
+
+	assert.ErrorIs(t, err, auth.ErrBadToken, "verify access token should return ErrBadToken after removal")
+}
+
+func TestAudit(t *testing.T) {
+
+	sink := auth.NewDBAuditSink(db)
+
+	authService := auth.NewService(&auth.Config{
+		Secret: "secret",
+	}, auth.WithAuditSink(sink))
+
+	ctx := context.Background()
+	ctx = database.WithContext(ctx, db)
+	ctx = auth.WithRequestInfo(ctx, auth.RequestInfo{
+		IP:        "203.0.113.7",
+		UserAgent: "mo-test",
+		RequestID: "req-1",
+	})
+
+	email := "audit@email.com"
+
+	registerRes, err := authService.Register(ctx, &auth.RegisterRequest{
+		Email:    email,
+		Password: "1234567890",
+	})
+
+	assert.NoError(t, err, "register should not return error")
+
+	_, err = authService.Login(ctx, &auth.LoginRequest{
+		Email:    email,
+		Password: "wrongpassword",
+	})
+
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	tokens, err := authService.CreateTokens(ctx, registerRes.AuthUserID)
+
+	assert.NoError(t, err, "create tokens should not return error")
+
+	err = authService.ChangePassword(ctx, &auth.ChangePasswordRequest{
+		Email:       email,
+		OldPassword: "wrongpassword",
+		NewPassword: "0987654321",
+	})
+
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	err = authService.ChangePassword(ctx, &auth.ChangePasswordRequest{
+		Email:       email,
+		OldPassword: "1234567890",
+		NewPassword: "0987654321",
+	})
+
+	assert.NoError(t, err, "change password should not return error")
+
+	_, err = authService.Verify(ctx, tokens.AccessToken, auth.TokenTypeAccess)
+
+	assert.ErrorIs(t, err, auth.ErrBadToken, "verify access token should return ErrBadToken after a password change")
+
+	_, err = authService.Verify(ctx, tokens.RefreshToken, auth.TokenTypeRefresh)
+
+	assert.ErrorIs(t, err, auth.ErrBadToken, "verify refresh token should return ErrBadToken after a password change")
+
+	_, err = authService.Login(ctx, &auth.LoginRequest{
+		Email:    email,
+		Password: "0987654321",
+	})
+
+	assert.NoError(t, err, "login with new password should not return error")
+
+	events, err := sink.RecentActivity(ctx, registerRes.AuthUserID, 10)
+
+	assert.NoError(t, err, "recent activity should not return error")
+
+	types := []auth.AuditEventType{}
+
+	for _, event := range events {
+		types = append(types, event.Type)
+
+		assert.Equal(t, "203.0.113.7", event.IP)
+		assert.Equal(t, "mo-test", event.UserAgent)
+		assert.Equal(t, "req-1", event.RequestID)
+	}
+
+	assert.ElementsMatch(t, []auth.AuditEventType{
+		auth.AuditRegistered,
+		auth.AuditLoginFailed,
+		auth.AuditPasswordChangeFailed,
+		auth.AuditPasswordChanged,
+		auth.AuditLoginSucceeded,
+	}, types)
+
+	failures, err := sink.Query(ctx, &auth.AuditQuery{
+		AuthUserID: registerRes.AuthUserID,
+		Types:      []auth.AuditEventType{auth.AuditLoginFailed},
+	})
+
+	assert.NoError(t, err, "query should not return error")
+	assert.Len(t, failures, 1)
+
+	// Oversized headers are cut to the column sizes
+	ctx = auth.WithRequestInfo(ctx, auth.RequestInfo{
+		IP:        strings.Repeat("1", 100),
+		UserAgent: strings.Repeat("é", 1000),
+		RequestID: strings.Repeat("r", 100),
+	})
+
+	_, err = authService.Login(ctx, &auth.LoginRequest{
+		Email:    email,
+		Password: "wrongpassword",
+	})
+
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	failures, err = sink.Query(ctx, &auth.AuditQuery{
+		AuthUserID: registerRes.AuthUserID,
+		Types:      []auth.AuditEventType{auth.AuditLoginFailed},
+	})
+
+	assert.NoError(t, err, "query should not return error")
+	assert.Len(t, failures, 2)
+
+	for _, event := range failures {
+		if event.RequestID == "req-1" {
+			continue
+		}
+
+		assert.Equal(t, strings.Repeat("1", 45), event.IP)
+		assert.Equal(t, strings.Repeat("é", 512), event.UserAgent)
+		assert.Equal(t, strings.Repeat("r", 64), event.RequestID)
+	}
+}
+
+func TestHooks(t *testing.T) {
+
+	authService := auth.NewService(&auth.Config{
+		Secret: "secret",
+	})
+
+	errVetoed := errors.New("vetoed")
+
+	authService.Hooks().Registered.Before(func(ctx context.Context, event auth.RegisteredEvent) error {
+		if event.Email == "veto@email.com" {
+			return errVetoed
+		}
+
+		return nil
+	})
+
+	// A panicking after hook does not crash the process nor stop the others
+	authService.Hooks().Registered.After(func(ctx context.Context, event auth.RegisteredEvent) {
+		panic("after hook")
+	})
+
+	registered := make(chan auth.RegisteredEvent, 1)
+
+	authService.Hooks().Registered.After(func(ctx context.Context, event auth.RegisteredEvent) {
+		registered <- event
+	})
+
+	ctx := context.Background()
+	ctx = database.WithContext(ctx, db)
+
+	// Veto rolls back the registration
+	_, err := authService.Register(ctx, &auth.RegisterRequest{
+		Email:    "veto@email.com",
+		Password: "1234567890",
+	})
+
+	assert.ErrorIs(t, err, errVetoed, "register should return the veto error")
+
+	_, err = authService.Login(ctx, &auth.LoginRequest{
+		Email:    "veto@email.com",
+		Password: "1234567890",
+	})
+
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials, "vetoed registration should not be persisted")
+
+	// After hooks only fire when the request transaction commits
+	e := echo.New()
+	e.Use(database.GlobalMiddleware(db), database.TxMiddleware())
+
+	e.POST("/register/:email", func(c echo.Context) error {
+		_, err := authService.Register(c.Request().Context(), &auth.RegisterRequest{
+			Email:    c.Param("email"),
+			Password: "1234567890",
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if c.QueryParam("fail") != "" {
+			return echo.ErrInternalServerError
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/register/rollback@email.com?fail=1", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	select {
+	case event := <-registered:
+		t.Fatalf("after hook fired for rolled back registration of %s", event.Email)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/register/hooks@email.com", nil))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	select {
+	case event := <-registered:
+		assert.Equal(t, "hooks@email.com", event.Email)
+	case <-time.After(time.Second):
+		t.Fatal("after hook did not fire after commit")
+	}
+}
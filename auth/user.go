@@ -4,11 +4,15 @@ import (
 	"context"
 	"time"
 
+	"github.com/joelywz/mo/database"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/schema"
 )
 
-var _ bun.BeforeAppendModelHook = (*User)(nil)
+var (
+	_ bun.BeforeAppendModelHook = (*User)(nil)
+	_ database.IndexedModel     = (*User)(nil)
+)
 
 type User struct {
 	bun.BaseModel `bun:"auth_users"`
@@ -27,3 +31,10 @@ func (u *User) BeforeAppendModel(ctx context.Context, query schema.Query) error
 
 	return nil
 }
+
+// Indexes implements database.IndexedModel.
+func (u *User) Indexes() []database.Index {
+	return []database.Index{
+		{Name: "auth_users_user_id_idx", Columns: []string{"user_id"}},
+	}
+}
@@ -0,0 +1,44 @@
+// Command mo runs the mo tooling. Migrations run through it are the SQL
+// migrations found in the migrations directory, apps with Go migrations
+// embed migratecli.Command instead. Destructive migrations only run with
+// up -allow-destructive.
+//
+//	mo migrate [-dir directory] <command> [arguments]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/joelywz/mo/database/migratecli"
+)
+
+func main() {
+
+	if len(os.Args) < 2 || os.Args[1] != "migrate" {
+		fmt.Fprintln(os.Stderr, "Usage: mo migrate [-dir directory] <command> [arguments]")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cmd := &migratecli.Command{
+		GuardDestructive: true,
+		Stdout:           os.Stdout,
+		Stderr:           os.Stderr,
+	}
+
+	if err := cmd.Run(ctx, os.Args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
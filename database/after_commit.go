@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"sync"
+)
+
+type AfterCommitKey struct{}
+
+type afterCommitQueue struct {
+	mu     sync.Mutex
+	fns    []func(ctx context.Context)
+	parent *afterCommitQueue
+}
+
+// AfterCommit registers fn to run once the transaction started by RunInTx
+// or TxMiddleware commits. Nothing runs if the transaction rolls back.
+// Outside of such a transaction there is nothing to wait for, so fn runs
+// immediately.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	queue, ok := ctx.Value(AfterCommitKey{}).(*afterCommitQueue)
+
+	if !ok {
+		fn(ctx)
+		return
+	}
+
+	queue.add(fn)
+}
+
+// withAfterCommitQueue returns a context collecting the AfterCommit
+// callbacks of a transaction. The queue of an enclosing transaction in ctx
+// becomes its parent, so that callbacks of savepoints wait for the
+// outermost commit.
+func withAfterCommitQueue(ctx context.Context) (context.Context, *afterCommitQueue) {
+
+	queue := &afterCommitQueue{}
+	queue.parent, _ = ctx.Value(AfterCommitKey{}).(*afterCommitQueue)
+
+	return context.WithValue(ctx, AfterCommitKey{}, queue), queue
+}
+
+func (q *afterCommitQueue) add(fns ...func(ctx context.Context)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.fns = append(q.fns, fns...)
+}
+
+// commit hands the callbacks to the parent queue, or runs them when q
+// belongs to the outermost transaction.
+func (q *afterCommitQueue) commit(ctx context.Context) {
+	q.mu.Lock()
+	fns := q.fns
+	q.fns = nil
+	q.mu.Unlock()
+
+	if q.parent != nil {
+		q.parent.add(fns...)
+		return
+	}
+
+	for _, fn := range fns {
+		fn(ctx)
+	}
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff returns the delay before retry attempt n (starting at 1), growing
+// exponentially from base and capped at max. Half of the delay is jittered
+// so that concurrent workers do not retry in lockstep.
+func Backoff(n int, base time.Duration, max time.Duration) time.Duration {
+	if n < 1 {
+		n = 1
+	}
+
+	d := base
+
+	for i := 1; i < n && d < max; i++ {
+		d *= 2
+	}
+
+	if d > max {
+		d = max
+	}
+
+	if d <= 1 {
+		return d
+	}
+
+	half := d / 2
+
+	return half + rand.N(d-half)
+}
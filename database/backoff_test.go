@@ -0,0 +1,22 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+
+	base := 100 * time.Millisecond
+	max := 2 * time.Second
+
+	for n, expected := range []time.Duration{base, base, 2 * base, 4 * base, 8 * base, 16 * base, max, max} {
+		d := database.Backoff(n, base, max)
+
+		assert.GreaterOrEqual(t, d, expected/2, "attempt %d", n)
+		assert.Less(t, d, expected, "attempt %d", n)
+	}
+}
@@ -7,11 +7,14 @@ import (
 	"github.com/uptrace/bun"
 )
 
+// SqlDatabaseManager manages a database and its connection pool. Db and
+// Bun lazily open a single pool and return it on every call until Close.
 type SqlDatabaseManager interface {
 	Create() error
 	Drop() error
 	Db() (*sql.DB, error)
 	Bun() (*bun.DB, error)
+	Close() error
 }
 
 func NewManager(cfg *Config) (SqlDatabaseManager, error) {
@@ -29,6 +32,10 @@ func getSqlManager(cfg *Config) (SqlDatabaseManager, error) {
 	switch cfg.Dialect {
 	case "mysql":
 		return NewMySQLManager(cfg)
+	case "postgres":
+		return NewPostgresManager(cfg)
+	case "sqlite":
+		return NewSQLiteManager(cfg)
 	default:
 		return nil, fmt.Errorf("unsupported dialect: %s", cfg.Dialect)
 	}
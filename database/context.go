@@ -9,6 +9,8 @@ import (
 
 type BunKey struct{}
 
+type RequestIDKey struct{}
+
 var (
 	ErrNoBunInContext = errors.New("no bun in context")
 )
@@ -19,12 +21,31 @@ func WithContext(ctx context.Context, bun bun.IDB) context.Context {
 }
 
 // FromContext retrieves the database connection from the context.
-// Returns ErrNoBunInContext if the database connection is not found.
+// Returns ErrNoBunInContext if the database connection is not found. A
+// Router is resolved to its primary when the context comes from
+// WithPrimary.
 func FromContext(ctx context.Context) (bun.IDB, error) {
 	bun, ok := ctx.Value(BunKey{}).(bun.IDB)
 
 	if !ok {
 		return nil, ErrNoBunInContext
 	}
+
+	if router, ok := bun.(*Router); ok && usePrimary(ctx) {
+		return router.Primary(), nil
+	}
+
 	return bun, nil
 }
+
+// WithRequestID returns a new context carrying the ID of the request it
+// serves, logged with its queries.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, RequestIDKey{}, requestID)
+}
+
+// RequestIDFromContext retrieves the request ID from the context, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(RequestIDKey{}).(string)
+	return requestID
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+var dialectMigrationName = regexp.MustCompile(`^(\d{14})_([0-9a-z_]+)\.tx\.(up|down)\.sql$`)
+
+// DialectMigrations returns the migrations stored under root in fsys, with
+// one directory per dialect (mysql, pg and sqlite) holding the same
+// NAME_COMMENT.tx.up.sql and NAME_COMMENT.tx.down.sql files written for
+// that dialect. Each migration runs the file of the dialect of the database
+// being migrated. The files of the mysql directory name the migrations.
+func DialectMigrations(fsys fs.FS, root string) (*migrate.Migrations, error) {
+
+	entries, err := fs.ReadDir(fsys, path.Join(root, "mysql"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := migrate.NewMigrations()
+	seen := make(map[string]bool)
+
+	for _, entry := range entries {
+		matches := dialectMigrationName.FindStringSubmatch(entry.Name())
+
+		if matches == nil {
+			return nil, fmt.Errorf("unsupported migration name %q", entry.Name())
+		}
+
+		name, comment := matches[1], matches[2]
+
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		migrations.Add(migrate.Migration{
+			Name:    name,
+			Comment: comment,
+			Up:      dialectMigrationFunc(fsys, root, name+"_"+comment+".tx.up.sql"),
+			Down:    dialectMigrationFunc(fsys, root, name+"_"+comment+".tx.down.sql"),
+		})
+	}
+
+	return migrations, nil
+}
+
+// dialectMigrationFunc runs file from the directory of the dialect of db.
+func dialectMigrationFunc(fsys fs.FS, root string, file string) migrate.MigrationFunc {
+	return func(ctx context.Context, db *bun.DB) error {
+
+		dialect := db.Dialect().Name().String()
+		name := path.Join(root, dialect, file)
+
+		if _, err := fs.Stat(fsys, name); err != nil {
+			return fmt.Errorf("no migrations for dialect %s: %w", dialect, err)
+		}
+
+		return migrate.NewSQLMigrationFunc(fsys, name)(ctx, db)
+	}
+}
@@ -0,0 +1,448 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// Index is an index of a table.
+type Index struct {
+	// Name of the index, generated from the table and columns when empty.
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+// IndexedModel is implemented by models declaring the indexes their table
+// needs besides its primary key and unique columns, for DetectDrift.
+type IndexedModel interface {
+	Indexes() []Index
+}
+
+// TableSchema is a table of the live database.
+type TableSchema struct {
+	Name string
+	// Columns maps the name of every column to its type.
+	Columns map[string]string
+	Indexes []Index
+}
+
+type DriftKind string
+
+const (
+	DriftMissingTable  DriftKind = "missing table"
+	DriftMissingColumn DriftKind = "missing column"
+	DriftTypeMismatch  DriftKind = "type mismatch"
+	DriftMissingIndex  DriftKind = "missing index"
+)
+
+// Drift is a difference between a model and the live database.
+type Drift struct {
+	Kind   DriftKind
+	Table  string
+	Column string
+	Index  *Index
+	// Expected is the type of the column in the model, Actual in the
+	// database.
+	Expected string
+	Actual   string
+
+	table *schema.Table
+	field *schema.Field
+}
+
+func (d Drift) String() string {
+	switch d.Kind {
+	case DriftMissingTable:
+		return fmt.Sprintf("%s: %s", d.Kind, d.Table)
+	case DriftMissingColumn:
+		return fmt.Sprintf("%s: %s.%s", d.Kind, d.Table, d.Column)
+	case DriftTypeMismatch:
+		return fmt.Sprintf("%s: %s.%s is %s, model expects %s", d.Kind, d.Table, d.Column, d.Actual, d.Expected)
+	default:
+		return fmt.Sprintf("%s: %s (%s)", d.Kind, d.Table, strings.Join(d.Index.Columns, ", "))
+	}
+}
+
+// DetectDrift compares models, such as (*auth.User)(nil), to the live
+// schema of db and returns their missing tables, columns and indexes, and
+// the columns whose type differs.
+func DetectDrift(ctx context.Context, db *bun.DB, models ...any) ([]Drift, error) {
+
+	live, err := InspectSchema(ctx, db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var drifts []Drift
+
+	for _, model := range models {
+		table := db.Table(reflect.TypeOf(model))
+		liveTable, ok := live[table.Name]
+
+		if !ok {
+			drifts = append(drifts, Drift{Kind: DriftMissingTable, Table: table.Name, table: table})
+			continue
+		}
+
+		for _, field := range table.Fields {
+			actual, ok := liveTable.Columns[field.Name]
+
+			if !ok {
+				drifts = append(drifts, Drift{Kind: DriftMissingColumn, Table: table.Name, Column: field.Name, table: table, field: field})
+				continue
+			}
+
+			expected := field.CreateTableSQLType
+
+			if !sameType(db.Dialect().Name(), expected, actual) {
+				drifts = append(drifts, Drift{
+					Kind:     DriftTypeMismatch,
+					Table:    table.Name,
+					Column:   field.Name,
+					Expected: expected,
+					Actual:   actual,
+					table:    table,
+					field:    field,
+				})
+			}
+		}
+
+		for _, index := range modelIndexes(table) {
+			if !hasIndex(liveTable.Indexes, index) {
+				index := index
+				drifts = append(drifts, Drift{Kind: DriftMissingIndex, Table: table.Name, Index: &index, table: table})
+			}
+		}
+	}
+
+	return drifts, nil
+}
+
+// DriftSQL returns a skeleton migration resolving drifts, to be reviewed
+// before it runs.
+func DriftSQL(db *bun.DB, drifts []Drift) (up string, down string) {
+
+	var ups, downs []string
+
+	for _, d := range drifts {
+		switch d.Kind {
+		case DriftMissingTable:
+			ups = append(ups, db.NewCreateTable().Model(d.table.ZeroIface).String())
+			downs = append(downs, db.Formatter().FormatQuery("DROP TABLE IF EXISTS ?", bun.Ident(d.Table)))
+
+			if indexed, ok := d.table.ZeroIface.(IndexedModel); ok {
+				for _, index := range indexed.Indexes() {
+					ups = append(ups, createIndexSQL(db, d.Table, index))
+				}
+			}
+		case DriftMissingColumn:
+			definition := d.field.CreateTableSQLType
+
+			if d.field.NotNull {
+				definition += " NOT NULL"
+			}
+
+			if d.field.SQLDefault != "" {
+				definition += " DEFAULT " + d.field.SQLDefault
+			}
+
+			ups = append(ups, db.Formatter().FormatQuery("ALTER TABLE ? ADD COLUMN ? "+definition, bun.Ident(d.Table), bun.Ident(d.Column)))
+			downs = append(downs, db.Formatter().FormatQuery("ALTER TABLE ? DROP COLUMN ?", bun.Ident(d.Table), bun.Ident(d.Column)))
+		case DriftTypeMismatch:
+			ups = append(ups, alterTypeSQL(db, d.Table, d.Column, d.Expected))
+			downs = append(downs, alterTypeSQL(db, d.Table, d.Column, d.Actual))
+		case DriftMissingIndex:
+			ups = append(ups, createIndexSQL(db, d.Table, *d.Index))
+			downs = append(downs, dropIndexSQL(db, d.Table, *d.Index))
+		}
+	}
+
+	slices.Reverse(downs)
+
+	return joinStatements(ups), joinStatements(downs)
+}
+
+// InspectSchema returns the tables of the live database by name.
+func InspectSchema(ctx context.Context, db *bun.DB) (map[string]*TableSchema, error) {
+
+	var columnsQuery, indexesQuery string
+
+	switch db.Dialect().Name() {
+	case dialect.MySQL:
+		columnsQuery = `SELECT table_name AS table_name, column_name AS column_name, column_type AS column_type
+			FROM information_schema.columns
+			WHERE table_schema = DATABASE()`
+		indexesQuery = `SELECT table_name AS table_name, index_name AS index_name, non_unique = 0 AS is_unique, column_name AS column_name
+			FROM information_schema.statistics
+			WHERE table_schema = DATABASE()
+			ORDER BY table_name, index_name, seq_in_index`
+	case dialect.PG:
+		columnsQuery = `SELECT table_name, column_name,
+				CASE WHEN character_maximum_length IS NULL THEN udt_name
+				ELSE udt_name || '(' || character_maximum_length || ')' END AS column_type
+			FROM information_schema.columns
+			WHERE table_schema = current_schema()`
+		indexesQuery = `SELECT t.relname AS table_name, i.relname AS index_name, ix.indisunique AS is_unique, a.attname AS column_name
+			FROM pg_index ix
+			JOIN pg_class t ON t.oid = ix.indrelid
+			JOIN pg_class i ON i.oid = ix.indexrelid
+			JOIN pg_namespace n ON n.oid = t.relnamespace
+			JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) ON true
+			JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+			WHERE n.nspname = current_schema()
+			ORDER BY t.relname, i.relname, k.ord`
+	case dialect.SQLite:
+		columnsQuery = `SELECT m.name AS table_name, p.name AS column_name, p.type AS column_type
+			FROM sqlite_master m
+			JOIN pragma_table_info(m.name) p
+			WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'`
+		indexesQuery = `SELECT m.name AS table_name, l.name AS index_name, l."unique" AS is_unique, i.name AS column_name
+			FROM sqlite_master m
+			JOIN pragma_index_list(m.name) l
+			JOIN pragma_index_info(l.name) i
+			WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'
+			ORDER BY m.name, l.name, i.seqno`
+	default:
+		return nil, fmt.Errorf("schema inspection is not supported for %s", db.Dialect().Name())
+	}
+
+	var columns []struct {
+		TableName  string `bun:"table_name"`
+		ColumnName string `bun:"column_name"`
+		ColumnType string `bun:"column_type"`
+	}
+
+	if err := db.NewRaw(columnsQuery).Scan(ctx, &columns); err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string]*TableSchema)
+
+	for _, c := range columns {
+		table, ok := tables[c.TableName]
+
+		if !ok {
+			table = &TableSchema{Name: c.TableName, Columns: make(map[string]string)}
+			tables[c.TableName] = table
+		}
+
+		table.Columns[c.ColumnName] = c.ColumnType
+	}
+
+	var indexes []struct {
+		TableName  string `bun:"table_name"`
+		IndexName  string `bun:"index_name"`
+		IsUnique   bool   `bun:"is_unique"`
+		ColumnName string `bun:"column_name"`
+	}
+
+	if err := db.NewRaw(indexesQuery).Scan(ctx, &indexes); err != nil {
+		return nil, err
+	}
+
+	for _, i := range indexes {
+		table, ok := tables[i.TableName]
+
+		if !ok {
+			continue
+		}
+
+		n := len(table.Indexes)
+
+		if n == 0 || table.Indexes[n-1].Name != i.IndexName {
+			table.Indexes = append(table.Indexes, Index{Name: i.IndexName, Unique: i.IsUnique})
+			n++
+		}
+
+		table.Indexes[n-1].Columns = append(table.Indexes[n-1].Columns, i.ColumnName)
+	}
+
+	return tables, nil
+}
+
+// modelIndexes returns the unique columns of table, then the indexes
+// declared by its model.
+func modelIndexes(table *schema.Table) []Index {
+
+	var indexes []Index
+	var groups []string
+
+	for group := range table.Unique {
+		groups = append(groups, group)
+	}
+
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		index := Index{Unique: true}
+
+		for _, field := range table.Unique[group] {
+			index.Columns = append(index.Columns, field.Name)
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	if indexed, ok := table.ZeroIface.(IndexedModel); ok {
+		indexes = append(indexes, indexed.Indexes()...)
+	}
+
+	return indexes
+}
+
+// hasIndex reports whether indexes has one on the columns of index, unique
+// if index is.
+func hasIndex(indexes []Index, index Index) bool {
+	for _, live := range indexes {
+		if (live.Unique || !index.Unique) && slices.EqualFunc(live.Columns, index.Columns, strings.EqualFold) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// typeAliases maps type names to the ones reported by the databases.
+var typeAliases = map[dialect.Name]map[string]string{
+	dialect.MySQL: {
+		"boolean":          "tinyint(1)",
+		"bool":             "tinyint(1)",
+		"integer":          "int",
+		"double precision": "double",
+		"real":             "double",
+	},
+	dialect.PG: {
+		"bigint":                      "int8",
+		"bigserial":                   "int8",
+		"integer":                     "int4",
+		"int":                         "int4",
+		"serial":                      "int4",
+		"smallint":                    "int2",
+		"boolean":                     "bool",
+		"double precision":            "float8",
+		"real":                        "float4",
+		"character varying":           "varchar",
+		"character":                   "bpchar",
+		"char":                        "bpchar",
+		"timestamp with time zone":    "timestamptz",
+		"timestamp without time zone": "timestamp",
+	},
+}
+
+// sameType reports whether the model type expected and the live type
+// actual are the same. A type without a length matches any length.
+func sameType(name dialect.Name, expected string, actual string) bool {
+
+	normalize := func(t string) (string, string) {
+		t = strings.ToLower(strings.Join(strings.Fields(t), " "))
+		base, params, _ := strings.Cut(t, "(")
+
+		base = strings.TrimSpace(base)
+
+		if alias, ok := typeAliases[name][base]; ok {
+			base = alias
+		}
+
+		if params != "" {
+			params = "(" + strings.ReplaceAll(params, " ", "")
+		}
+
+		// Aliases may carry their own length
+		if strings.Contains(base, "(") {
+			base, params, _ = strings.Cut(base, "(")
+			params = "(" + params
+		}
+
+		return base, params
+	}
+
+	expectedBase, expectedParams := normalize(expected)
+	actualBase, actualParams := normalize(actual)
+
+	if expectedBase != actualBase {
+		return false
+	}
+
+	return expectedParams == "" || actualParams == "" || expectedParams == actualParams
+}
+
+func createIndexSQL(db *bun.DB, table string, index Index) string {
+
+	query := "CREATE INDEX ? ON ? (?)"
+
+	if index.Unique {
+		query = "CREATE UNIQUE INDEX ? ON ? (?)"
+	}
+
+	return db.Formatter().FormatQuery(query, bun.Ident(indexName(table, index)), bun.Ident(table), identList(index.Columns))
+}
+
+func dropIndexSQL(db *bun.DB, table string, index Index) string {
+
+	if db.Dialect().Name() == dialect.MySQL {
+		return db.Formatter().FormatQuery("DROP INDEX ? ON ?", bun.Ident(indexName(table, index)), bun.Ident(table))
+	}
+
+	return db.Formatter().FormatQuery("DROP INDEX ?", bun.Ident(indexName(table, index)))
+}
+
+func alterTypeSQL(db *bun.DB, table string, column string, typ string) string {
+	switch db.Dialect().Name() {
+	case dialect.MySQL:
+		return db.Formatter().FormatQuery("ALTER TABLE ? MODIFY COLUMN ? "+typ, bun.Ident(table), bun.Ident(column))
+	case dialect.PG:
+		return db.Formatter().FormatQuery("ALTER TABLE ? ALTER COLUMN ? TYPE "+typ, bun.Ident(table), bun.Ident(column))
+	default:
+		return fmt.Sprintf("-- %s cannot change the type of %s.%s to %s, rebuild the table", db.Dialect().Name(), table, column, typ)
+	}
+}
+
+func indexName(table string, index Index) string {
+	if index.Name != "" {
+		return index.Name
+	}
+
+	return table + "_" + strings.Join(index.Columns, "_") + "_idx"
+}
+
+func identList(columns []string) any {
+
+	idents := make([]bun.Ident, len(columns))
+
+	for i, column := range columns {
+		idents[i] = bun.Ident(column)
+	}
+
+	return bun.In(idents)
+}
+
+// joinStatements joins statements into the content of a SQL migration.
+func joinStatements(statements []string) string {
+
+	var b strings.Builder
+
+	for i, statement := range statements {
+		if i > 0 {
+			b.WriteString("\n--bun:split\n\n")
+		}
+
+		if strings.HasPrefix(statement, "--") {
+			b.WriteString(statement + "\n")
+			continue
+		}
+
+		b.WriteString(statement + ";\n")
+	}
+
+	return b.String()
+}
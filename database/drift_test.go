@@ -0,0 +1,89 @@
+package database_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+type product struct {
+	bun.BaseModel `bun:"products"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	SKU           string `bun:"sku,notnull,unique,type:varchar(32)"`
+	Name          string `bun:"name,notnull,type:varchar(128)"`
+	Category      string `bun:"category,notnull,default:'',type:varchar(64)"`
+}
+
+func (p *product) Indexes() []database.Index {
+	return []database.Index{{Columns: []string{"category", "name"}}}
+}
+
+type order struct {
+	bun.BaseModel `bun:"orders"`
+	ID            int64 `bun:"id,pk,autoincrement"`
+	ProductID     int64 `bun:"product_id,notnull"`
+}
+
+func TestDetectDrift(t *testing.T) {
+
+	manager, purge, err := dbtest.SQLiteManager("mo_drift")
+	assert.NoError(t, err)
+
+	defer purge()
+
+	ctx := context.Background()
+
+	db, err := manager.Bun()
+	assert.NoError(t, err)
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE products (id INTEGER PRIMARY KEY, sku VARCHAR(16) NOT NULL)")
+	assert.NoError(t, err)
+
+	drifts, err := database.DetectDrift(ctx, db, (*product)(nil), (*order)(nil))
+	assert.NoError(t, err)
+
+	var kinds []string
+
+	for _, d := range drifts {
+		kinds = append(kinds, d.String())
+	}
+
+	assert.ElementsMatch(t, []string{
+		"type mismatch: products.sku is VARCHAR(16), model expects varchar(32)",
+		"missing column: products.name",
+		"missing column: products.category",
+		"missing index: products (sku)",
+		"missing index: products (category, name)",
+		"missing table: orders",
+	}, kinds)
+
+	up, down := database.DriftSQL(db, drifts)
+
+	assert.Contains(t, up, `ALTER TABLE "products" ADD COLUMN "category" varchar(64) NOT NULL DEFAULT ''`)
+	assert.Contains(t, up, `CREATE UNIQUE INDEX "products_sku_idx" ON "products" ("sku")`)
+	assert.Contains(t, up, `CREATE TABLE "orders"`)
+	assert.Contains(t, up, "-- sqlite cannot change the type of products.sku")
+	assert.Contains(t, down, `DROP TABLE IF EXISTS "orders"`)
+
+	// The skeleton resolves everything but the type change
+	migrations := migrate.NewMigrations()
+	migrations.Add(migrate.Migration{
+		Name: "20240101000000",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			return migrate.Exec(ctx, db, strings.NewReader(up), false)
+		},
+	})
+
+	assert.NoError(t, database.MigrateUp(manager, migrations))
+
+	drifts, err = database.DetectDrift(ctx, db, (*product)(nil), (*order)(nil))
+	assert.NoError(t, err)
+	assert.Len(t, drifts, 1)
+	assert.Equal(t, database.DriftTypeMismatch, drifts[0].Kind)
+}
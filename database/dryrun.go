@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/uptrace/bun/migrate"
+)
+
+var (
+	ErrDestructiveMigration = errors.New("destructive migration")
+)
+
+var destructiveStatements = []*regexp.Regexp{
+	regexp.MustCompile(`^DROP\s`),
+	regexp.MustCompile(`^TRUNCATE\s`),
+	regexp.MustCompile(`^DELETE\s+FROM\s+[^\s]+$`),
+	regexp.MustCompile(`^ALTER\s+TABLE\s.*\sDROP\s`),
+	regexp.MustCompile(`^ALTER\s+TABLE\s.*\s(MODIFY|CHANGE)\s`),
+	regexp.MustCompile(`^ALTER\s+TABLE\s.*\sALTER\s+(COLUMN\s+)?[^\s]+\s+(SET\s+DATA\s+)?TYPE\s`),
+}
+
+// Statement is a statement a migration would run.
+type Statement struct {
+	Query string
+	// Destructive is set for statements that may lose data: drops,
+	// truncations, unfiltered deletes and column type changes.
+	Destructive bool
+}
+
+// MigrationPlan is what a pending migration would run.
+type MigrationPlan struct {
+	Name       string
+	Comment    string
+	Statements []Statement
+}
+
+func (p MigrationPlan) String() string {
+	if p.Comment == "" {
+		return p.Name
+	}
+
+	return p.Name + "_" + p.Comment
+}
+
+// Destructive reports whether one of the statements of p is destructive.
+func (p MigrationPlan) Destructive() bool {
+	for _, s := range p.Statements {
+		if s.Destructive {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DryRun runs the pending migrations of migrator against a database
+// recording their writes instead of executing them, and returns what each
+// would run. Reads still go to the database of migrator so that Go
+// migrations can inspect it, but they do not see the writes of earlier
+// migrations.
+func DryRun(ctx context.Context, migrator *migrate.Migrator) ([]MigrationPlan, error) {
+
+	ms, err := migrator.MigrationsWithStatus(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recording, rec := newRecordingDB(migrator.DB())
+	defer recording.Close()
+
+	var plans []MigrationPlan
+
+	for _, m := range ms.Unapplied() {
+		plan := MigrationPlan{
+			Name:    m.Name,
+			Comment: m.Comment,
+		}
+
+		if m.Up != nil {
+			if err := m.Up(ctx, recording); err != nil {
+				return nil, fmt.Errorf("%s: %w", plan, err)
+			}
+		}
+
+		for _, query := range rec.take() {
+			plan.Statements = append(plan.Statements, Statement{
+				Query:       query,
+				Destructive: IsDestructive(query),
+			})
+		}
+
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
+
+// IsDestructive reports whether query may lose data.
+func IsDestructive(query string) bool {
+
+	query = sqlComments.ReplaceAllString(query, "")
+
+	for _, statement := range strings.Split(query, ";") {
+		statement = strings.ToUpper(strings.Join(strings.Fields(statement), " "))
+
+		for _, re := range destructiveStatements {
+			if re.MatchString(statement) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// CheckDestructive returns ErrDestructiveMigration naming the first
+// destructive plan, if any.
+func CheckDestructive(plans []MigrationPlan) error {
+	for _, p := range plans {
+		if p.Destructive() {
+			return fmt.Errorf("%w: %s", ErrDestructiveMigration, p)
+		}
+	}
+
+	return nil
+}
+
+// WritePlan writes the statements of plans to w as SQL, with a warning
+// above every destructive statement.
+func WritePlan(w io.Writer, plans []MigrationPlan) error {
+
+	if len(plans) == 0 {
+		_, err := fmt.Fprintln(w, "-- no pending migrations")
+		return err
+	}
+
+	for i, p := range plans {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "-- migration %s\n", p); err != nil {
+			return err
+		}
+
+		for _, s := range p.Statements {
+			if s.Destructive {
+				if _, err := fmt.Fprintln(w, "-- WARNING: destructive statement"); err != nil {
+					return err
+				}
+			}
+
+			if _, err := fmt.Fprintf(w, "%s;\n", strings.TrimSuffix(s.Query, ";")); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
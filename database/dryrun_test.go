@@ -0,0 +1,122 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+func TestDryRun(t *testing.T) {
+
+	manager, purge, err := dbtest.SQLiteManager("mo_dryrun")
+	assert.NoError(t, err)
+
+	defer purge()
+
+	ctx := context.Background()
+
+	db, err := manager.Bun()
+	assert.NoError(t, err)
+
+	exists := func(table string) bool {
+		count, err := db.NewSelect().Table("sqlite_master").Where("type = 'table' AND name = ?", table).Count(ctx)
+		assert.NoError(t, err)
+
+		return count > 0
+	}
+
+	migrations := migrate.NewMigrations()
+
+	migrations.Add(migrate.Migration{
+		Name:    "20240101000000",
+		Comment: "create_items",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			_, err := db.NewCreateTable().Model((*item)(nil)).Exec(ctx)
+			return err
+		},
+	})
+
+	t.Run("Plan", func(t *testing.T) {
+		var out bytes.Buffer
+
+		assert.NoError(t, database.MigrateUp(manager, migrations, database.WithDryRun(&out)))
+		assert.False(t, exists("items"), "dry run should not create tables")
+
+		assert.Contains(t, out.String(), "-- migration 20240101000000_create_items")
+		assert.Contains(t, out.String(), `CREATE TABLE "items"`)
+		assert.NotContains(t, out.String(), "WARNING")
+	})
+
+	t.Run("Destructive", func(t *testing.T) {
+		assert.NoError(t, database.MigrateUp(manager, migrations, database.WithDestructiveGuard(false)))
+		assert.True(t, exists("items"))
+
+		migrations.Add(migrate.Migration{
+			Name:    "20240102000000",
+			Comment: "drop_items",
+			Up: func(ctx context.Context, db *bun.DB) error {
+				// Reads go to the database
+				count, err := db.NewSelect().Model((*item)(nil)).Count(ctx)
+
+				if err != nil || count > 0 {
+					return err
+				}
+
+				_, err = db.NewDropTable().Model((*item)(nil)).Exec(ctx)
+				return err
+			},
+		})
+
+		var out bytes.Buffer
+
+		assert.NoError(t, database.MigrateUp(manager, migrations, database.WithDryRun(&out)))
+		assert.Contains(t, out.String(), "-- WARNING: destructive statement\nDROP TABLE \"items\";")
+		assert.Equal(t, 1, strings.Count(out.String(), "-- migration"), "applied migrations should not be planned")
+
+		err := database.MigrateUp(manager, migrations, database.WithDestructiveGuard(false))
+		assert.ErrorIs(t, err, database.ErrDestructiveMigration)
+		assert.True(t, exists("items"))
+
+		assert.NoError(t, database.MigrateUp(manager, migrations, database.WithDestructiveGuard(true)))
+		assert.False(t, exists("items"))
+	})
+}
+
+func TestIsDestructive(t *testing.T) {
+
+	destructive := []string{
+		"DROP TABLE items",
+		"drop index items_idx",
+		"TRUNCATE TABLE items",
+		"DELETE FROM items",
+		"ALTER TABLE items DROP COLUMN name",
+		"ALTER TABLE items MODIFY name VARCHAR(10)",
+		"ALTER TABLE items CHANGE name title VARCHAR(10)",
+		"ALTER TABLE items ALTER COLUMN name TYPE text",
+		"ALTER TABLE items ALTER name SET DATA TYPE text",
+		"CREATE TABLE a (id INT);\n-- clean up\nDROP TABLE b",
+	}
+
+	for _, query := range destructive {
+		assert.True(t, database.IsDestructive(query), query)
+	}
+
+	safe := []string{
+		"CREATE TABLE items (id INT)",
+		"ALTER TABLE items ADD COLUMN name TEXT",
+		"DELETE FROM items WHERE id = 1",
+		"ALTER TABLE items ALTER COLUMN name SET DEFAULT ''",
+		"-- DROP TABLE items\nCREATE INDEX items_idx ON items (id)",
+	}
+
+	for _, query := range safe {
+		assert.False(t, database.IsDestructive(query), query)
+	}
+}
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+
+	mysqlerrnum "github.com/bombsimon/mysql-error-numbers/v2"
+	"github.com/go-sql-driver/mysql"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var (
+	ErrUniqueViolation     = errors.New("unique constraint violation")
+	ErrForeignKeyViolation = errors.New("foreign key constraint violation")
+	ErrNotNull             = errors.New("not null constraint violation")
+	// ErrDeadlock is a transaction aborted because of concurrent
+	// transactions: a deadlock, a lock timeout, a serialization failure or
+	// a locked SQLite database. It may succeed if it runs again.
+	ErrDeadlock = errors.New("deadlock")
+	ErrNoRows   = errors.New("no rows")
+)
+
+var (
+	mysqlKey        = regexp.MustCompile("for key '([^']+)'")
+	mysqlConstraint = regexp.MustCompile("CONSTRAINT `([^`]+)`")
+	mysqlColumn     = regexp.MustCompile("(?:Column|Field) '([^']+)'")
+	sqliteColumns   = regexp.MustCompile(`[A-Z]+ constraint failed: ([^\s(]+(?:, [^\s(]+)*)`)
+)
+
+// Error is a driver error classified by ClassifyError.
+type Error struct {
+	// Kind is one of ErrUniqueViolation, ErrForeignKeyViolation,
+	// ErrNotNull, ErrDeadlock and ErrNoRows.
+	Kind error
+	// Constraint names the violated constraint when the driver reports it.
+	// MySQL reports the key of unique violations as table.key on 8.0 and
+	// later, and SQLite only reports the columns, as table.column.
+	Constraint string
+	// Column names the column of not null violations.
+	Column string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap makes errors.Is match both the kind and the driver error.
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// ClassifyError maps the driver error of err to an *Error whose kind can be
+// matched with errors.Is, whatever the dialect:
+//
+//	if errors.Is(database.ClassifyError(err), database.ErrUniqueViolation) {
+//		return ErrEmailExists
+//	}
+//
+// Other errors, and nil, are returned as they are.
+func ClassifyError(err error) error {
+
+	if err == nil {
+		return nil
+	}
+
+	var classified *Error
+
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Kind: ErrNoRows, Err: err}
+	}
+
+	var mysqlErr *mysql.MySQLError
+
+	if errors.As(err, &mysqlErr) {
+		return classifyMySQL(err, mysqlErr)
+	}
+
+	var pgErr pgdriver.Error
+
+	if errors.As(err, &pgErr) {
+		return classifyPG(err, pgErr)
+	}
+
+	var sqliteErr *sqlite.Error
+
+	if errors.As(err, &sqliteErr) {
+		return classifySQLite(err, sqliteErr)
+	}
+
+	return err
+}
+
+func classifyMySQL(err error, mysqlErr *mysql.MySQLError) error {
+
+	switch mysqlerrnum.FromNumber(int(mysqlErr.Number)) {
+	case mysqlerrnum.ErrDupEntry:
+		return &Error{Kind: ErrUniqueViolation, Constraint: submatch(mysqlKey, mysqlErr.Message), Err: err}
+	case mysqlerrnum.ErrRowIsReferenced, mysqlerrnum.ErrRowIsReferenced2, mysqlerrnum.ErrNoReferencedRow, mysqlerrnum.ErrNoReferencedRow2:
+		return &Error{Kind: ErrForeignKeyViolation, Constraint: submatch(mysqlConstraint, mysqlErr.Message), Err: err}
+	case mysqlerrnum.ErrBadNullError, mysqlerrnum.ErrNoDefaultForField:
+		return &Error{Kind: ErrNotNull, Column: submatch(mysqlColumn, mysqlErr.Message), Err: err}
+	case mysqlerrnum.ErrLockDeadlock, mysqlerrnum.ErrLockWaitTimeout:
+		return &Error{Kind: ErrDeadlock, Err: err}
+	default:
+		return err
+	}
+}
+
+func classifyPG(err error, pgErr pgdriver.Error) error {
+
+	switch pgErr.Field('C') {
+	case "23505": // unique_violation
+		return &Error{Kind: ErrUniqueViolation, Constraint: pgErr.Field('n'), Err: err}
+	case "23503": // foreign_key_violation
+		return &Error{Kind: ErrForeignKeyViolation, Constraint: pgErr.Field('n'), Err: err}
+	case "23502": // not_null_violation
+		return &Error{Kind: ErrNotNull, Column: pgErr.Field('c'), Err: err}
+	// serialization_failure, deadlock_detected, lock_not_available
+	case "40001", "40P01", "55P03":
+		return &Error{Kind: ErrDeadlock, Err: err}
+	default:
+		return err
+	}
+}
+
+func classifySQLite(err error, sqliteErr *sqlite.Error) error {
+
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return &Error{Kind: ErrUniqueViolation, Constraint: submatch(sqliteColumns, sqliteErr.Error()), Err: err}
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return &Error{Kind: ErrForeignKeyViolation, Err: err}
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		return &Error{Kind: ErrNotNull, Column: submatch(sqliteColumns, sqliteErr.Error()), Err: err}
+	}
+
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return &Error{Kind: ErrDeadlock, Err: err}
+	default:
+		return err
+	}
+}
+
+// submatch returns the first group of re in s, or "".
+func submatch(re *regexp.Regexp, s string) string {
+
+	m := re.FindStringSubmatch(s)
+
+	if m == nil {
+		return ""
+	}
+
+	return m[1]
+}
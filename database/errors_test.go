@@ -0,0 +1,116 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+type author struct {
+	bun.BaseModel `bun:"authors"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Email         string `bun:"email,notnull,unique"`
+}
+
+type book struct {
+	bun.BaseModel `bun:"books"`
+	ID            int64   `bun:"id,pk,autoincrement"`
+	AuthorID      int64   `bun:"author_id,notnull"`
+	Title         *string `bun:"title,notnull"`
+}
+
+func TestClassifyError(t *testing.T) {
+
+	db, purge, err := dbtest.SQLite("mo_errors")
+	assert.NoError(t, err)
+
+	defer purge()
+
+	ctx := context.Background()
+
+	_, err = db.NewCreateTable().Model((*author)(nil)).Exec(ctx)
+	assert.NoError(t, err)
+
+	_, err = db.NewCreateTable().
+		Model((*book)(nil)).
+		ForeignKey(`("author_id") REFERENCES "authors" ("id")`).
+		Exec(ctx)
+	assert.NoError(t, err)
+
+	title := "Title"
+
+	_, err = db.NewInsert().Model(&author{Email: "author@email.com"}).Exec(ctx)
+	assert.NoError(t, err)
+
+	t.Run("SQLite", func(t *testing.T) {
+		var classified *database.Error
+
+		_, err := db.NewInsert().Model(&author{Email: "author@email.com"}).Exec(ctx)
+		err = database.ClassifyError(err)
+		assert.ErrorIs(t, err, database.ErrUniqueViolation)
+		assert.ErrorAs(t, err, &classified)
+		assert.Equal(t, "authors.email", classified.Constraint)
+
+		_, err = db.NewInsert().Model(&book{AuthorID: 42, Title: &title}).Exec(ctx)
+		assert.ErrorIs(t, database.ClassifyError(err), database.ErrForeignKeyViolation)
+
+		_, err = db.NewInsert().Model(&book{AuthorID: 1}).Exec(ctx)
+		err = database.ClassifyError(err)
+		assert.ErrorIs(t, err, database.ErrNotNull)
+		assert.ErrorAs(t, err, &classified)
+		assert.Equal(t, "books.title", classified.Column)
+
+		err = db.NewSelect().Model(&book{}).Where("id = ?", 42).Scan(ctx)
+		err = database.ClassifyError(err)
+		assert.ErrorIs(t, err, database.ErrNoRows)
+		assert.ErrorIs(t, err, sql.ErrNoRows, "the driver error should still match")
+	})
+
+	t.Run("MySQL", func(t *testing.T) {
+		var classified *database.Error
+
+		err := database.ClassifyError(fmt.Errorf("insert: %w", &mysql.MySQLError{
+			Number:  1062,
+			Message: "Duplicate entry 'author@email.com' for key 'authors.email'",
+		}))
+		assert.ErrorIs(t, err, database.ErrUniqueViolation)
+		assert.ErrorAs(t, err, &classified)
+		assert.Equal(t, "authors.email", classified.Constraint)
+
+		var mysqlErr *mysql.MySQLError
+		assert.ErrorAs(t, err, &mysqlErr, "the driver error should still match")
+
+		err = database.ClassifyError(&mysql.MySQLError{
+			Number:  1452,
+			Message: "Cannot add or update a child row: a foreign key constraint fails (`mo`.`books`, CONSTRAINT `books_author_id_fkey` FOREIGN KEY (`author_id`) REFERENCES `authors` (`id`))",
+		})
+		assert.ErrorIs(t, err, database.ErrForeignKeyViolation)
+		assert.ErrorAs(t, err, &classified)
+		assert.Equal(t, "books_author_id_fkey", classified.Constraint)
+
+		err = database.ClassifyError(&mysql.MySQLError{Number: 1048, Message: "Column 'title' cannot be null"})
+		assert.ErrorIs(t, err, database.ErrNotNull)
+		assert.ErrorAs(t, err, &classified)
+		assert.Equal(t, "title", classified.Column)
+
+		assert.ErrorIs(t, database.ClassifyError(&mysql.MySQLError{Number: 1213}), database.ErrDeadlock)
+	})
+
+	t.Run("Unclassified", func(t *testing.T) {
+		boom := errors.New("boom")
+
+		assert.Equal(t, boom, database.ClassifyError(boom))
+		assert.Nil(t, database.ClassifyError(nil))
+
+		err := database.ClassifyError(sql.ErrNoRows)
+		assert.Equal(t, err, database.ClassifyError(err), "classifying twice should not wrap again")
+	})
+}
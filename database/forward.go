@@ -0,0 +1,184 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+
+	"github.com/uptrace/bun"
+)
+
+var errForwardPrepare = errors.New("prepared statements are not supported by forwarding databases")
+
+// querier is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// newTxDB returns a database running every query in tx, for code that needs
+// a *bun.DB. Transactions begun on it do nothing, tx is committed or rolled
+// back by its owner.
+func newTxDB(db *bun.DB, tx bun.Tx) *bun.DB {
+
+	sqldb := sql.OpenDB(&forwardConnector{target: tx.Tx})
+	sqldb.SetMaxOpenConns(1)
+
+	return bun.NewDB(sqldb, newDialect(db.Dialect()))
+}
+
+// forwardConnector opens connections running queries on target. Recording
+// connections only run reads and discard every other query.
+type forwardConnector struct {
+	target querier
+	record bool
+}
+
+func (c *forwardConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &forwardConn{target: c.target, record: c.record}, nil
+}
+
+func (c *forwardConnector) Driver() driver.Driver {
+	return forwardDriver{}
+}
+
+type forwardDriver struct{}
+
+func (forwardDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("the forwarding driver must be used with a connector")
+}
+
+type forwardConn struct {
+	target querier
+	record bool
+}
+
+var (
+	_ driver.ExecerContext  = (*forwardConn)(nil)
+	_ driver.QueryerContext = (*forwardConn)(nil)
+	_ driver.ConnBeginTx    = (*forwardConn)(nil)
+)
+
+func (c *forwardConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errForwardPrepare
+}
+
+func (c *forwardConn) Close() error {
+	return nil
+}
+
+func (c *forwardConn) Begin() (driver.Tx, error) {
+	return nopTx{}, nil
+}
+
+func (c *forwardConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return nopTx{}, nil
+}
+
+func (c *forwardConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+
+	if c.record {
+		return nopResult{}, nil
+	}
+
+	return c.target.ExecContext(ctx, query, namedValues(args)...)
+}
+
+func (c *forwardConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+
+	if c.record && !isReadQuery(query) {
+		return &forwardRows{}, nil
+	}
+
+	rows, err := c.target.QueryContext(ctx, query, namedValues(args)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &forwardRows{rows: rows}, nil
+}
+
+func namedValues(args []driver.NamedValue) []any {
+
+	values := make([]any, len(args))
+
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	return values
+}
+
+type nopTx struct{}
+
+func (nopTx) Commit() error {
+	return nil
+}
+
+func (nopTx) Rollback() error {
+	return nil
+}
+
+type nopResult struct{}
+
+func (nopResult) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+func (nopResult) RowsAffected() (int64, error) {
+	return 0, nil
+}
+
+// forwardRows returns the rows of a forwarded query, or no rows for a
+// discarded one.
+type forwardRows struct {
+	rows *sql.Rows
+}
+
+func (r *forwardRows) Columns() []string {
+	if r.rows == nil {
+		return nil
+	}
+
+	columns, _ := r.rows.Columns()
+
+	return columns
+}
+
+func (r *forwardRows) Close() error {
+	if r.rows == nil {
+		return nil
+	}
+
+	return r.rows.Close()
+}
+
+func (r *forwardRows) Next(dest []driver.Value) error {
+	if r.rows == nil || !r.rows.Next() {
+		if r.rows != nil && r.rows.Err() != nil {
+			return r.rows.Err()
+		}
+
+		return io.EOF
+	}
+
+	values := make([]any, len(dest))
+	pointers := make([]any, len(dest))
+
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	if err := r.rows.Scan(pointers...); err != nil {
+		return err
+	}
+
+	for i, value := range values {
+		dest[i] = value
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"time"
+	"unicode/utf8"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+const (
+	lockPollInterval = 100 * time.Millisecond
+	maxMySQLLockName = 64
+)
+
+var (
+	ErrLockNotAcquired = errors.New("lock not acquired")
+)
+
+// Lock acquires a named lock held by the database server, GET_LOCK on
+// MySQL and an advisory lock on Postgres, so that it is shared by every
+// process using the database. It waits up to timeout, a zero timeout only
+// tries once, and returns ErrLockNotAcquired if the lock is held elsewhere.
+// The lock lives on a dedicated connection until the returned function
+// releases it.
+//
+// SQLite has no named locks, a SQLite database is assumed to be used by a
+// single process and the lock always succeeds.
+func Lock(ctx context.Context, db *bun.DB, name string, timeout time.Duration) (func() error, error) {
+
+	if db.Dialect().Name() == dialect.SQLite {
+		return func() error { return nil }, nil
+	}
+
+	conn, err := db.Conn(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var release func() error
+
+	switch db.Dialect().Name() {
+	case dialect.MySQL:
+		release, err = lockMySQL(ctx, conn, name, timeout)
+	case dialect.PG:
+		release, err = lockPostgres(ctx, conn, name, timeout)
+	default:
+		err = fmt.Errorf("unsupported dialect for locking: %s", db.Dialect().Name())
+	}
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return func() error {
+		return errors.Join(release(), conn.Close())
+	}, nil
+}
+
+func lockMySQL(ctx context.Context, conn bun.Conn, name string, timeout time.Duration) (func() error, error) {
+
+	name = mysqlLockName(name)
+
+	var acquired sql.NullInt64
+
+	// GET_LOCK waits whole seconds, round up so a sub-second timeout waits
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, int(math.Ceil(timeout.Seconds()))).Scan(&acquired)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return nil, ErrLockNotAcquired
+	}
+
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+		return err
+	}, nil
+}
+
+// mysqlLockName shortens names longer than the 64 characters GET_LOCK
+// accepts, keeping a prefix for readability and a hash of the whole name.
+func mysqlLockName(name string) string {
+
+	if len(name) <= maxMySQLLockName {
+		return name
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(name))
+
+	suffix := fmt.Sprintf("#%016x", h.Sum64())
+	n := maxMySQLLockName - len(suffix)
+
+	// Do not split a multibyte character
+	for n > 0 && !utf8.RuneStart(name[n]) {
+		n--
+	}
+
+	return name[:n] + suffix
+}
+
+func lockPostgres(ctx context.Context, conn bun.Conn, name string, timeout time.Duration) (func() error, error) {
+
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	key := int64(h.Sum64())
+
+	deadline := time.Now().Add(timeout)
+
+	for {
+		var acquired bool
+
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(?)", key).Scan(&acquired)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if acquired {
+			break
+		}
+
+		if !time.Now().Before(deadline) {
+			return nil, ErrLockNotAcquired
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	return func() error {
+		_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(?)", key)
+		return err
+	}, nil
+}
+
+// SkipLocked locks the rows selected by q for update, skipping the rows
+// locked by other transactions, so that several workers can claim rows from
+// the same table:
+//
+//	tx.NewSelect().Model(&jobs).Limit(10).Apply(database.SkipLocked)
+//
+// SQLite has no row locks and serializes writing transactions instead, the
+// clause is left out there.
+func SkipLocked(q *bun.SelectQuery) *bun.SelectQuery {
+
+	if q.Dialect().Name() == dialect.SQLite {
+		return q
+	}
+
+	return q.For("UPDATE SKIP LOCKED")
+}
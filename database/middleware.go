@@ -1,7 +1,13 @@
 package database
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
 	"errors"
+	"io"
+	"log/slog"
+	"net/http"
 
 	"github.com/labstack/echo/v4"
 	"github.com/uptrace/bun"
@@ -24,45 +30,167 @@ func GlobalMiddleware(db *bun.DB) echo.MiddlewareFunc {
 	}
 }
 
-// TxMiddleware retrieves the database connection from the context,
-// initiates a transaction, and then substitutes the original database
-// connection in the context with this new transaction, using a
-// common interface.
-func TxMiddleware() echo.MiddlewareFunc {
+// RouterMiddleware introduces a session of the router into the context,
+// so that reads go to replicas until the request writes or begins a
+// transaction, after which it stays on the primary.
+func RouterMiddleware(r *Router) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			ctx := c.Request().Context()
 
-			db, err := FromContext(ctx)
+			ctx = WithContext(ctx, r.Session())
 
-			if err != nil {
-				return err
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
+
+const (
+	defaultRollbackStatus = http.StatusBadRequest
+	defaultRetryBodyLimit = 4 << 20
+)
+
+var errRollbackStatus = errors.New("rollback on response status")
+
+type TxOption func(o *txOptions)
+
+type txOptions struct {
+	rollbackStatus int
+	tx             sql.TxOptions
+	retry          *retryOptions
+	retryBodyLimit int64
+}
+
+// WithRollbackStatus rolls the transaction back when the handler responds
+// with status or more without returning an error, 400 by default. A zero
+// status commits whatever the response.
+func WithRollbackStatus(status int) TxOption {
+	return func(o *txOptions) {
+		o.rollbackStatus = status
+	}
+}
+
+// WithIsolation begins transactions with the isolation level.
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(o *txOptions) {
+		o.tx.Isolation = level
+	}
+}
+
+// WithReadOnly begins read-only transactions, for the drivers enforcing
+// them.
+func WithReadOnly() TxOption {
+	return func(o *txOptions) {
+		o.tx.ReadOnly = true
+	}
+}
+
+// WithRetry runs the rest of the chain again when the transaction fails
+// with an error for which IsRetryable is true and no response was written
+// yet, see RunInTxWithRetry. The request body is buffered to be read again,
+// up to WithRetryBodyLimit.
+func WithRetry(opts ...RetryOption) TxOption {
+	return func(o *txOptions) {
+		o.retry = newRetryOptions(opts)
+	}
+}
+
+// WithRetryBodyLimit caps the request body buffered by WithRetry to n
+// bytes, 4MB by default. Larger bodies are answered with 413.
+func WithRetryBodyLimit(n int64) TxOption {
+	return func(o *txOptions) {
+		o.retryBodyLimit = n
+	}
+}
+
+// TxMiddleware runs the rest of the chain in a transaction with RunInTx,
+// replacing the database connection in the context with the transaction.
+// The transaction rolls back if a handler returns an error, responds with
+// an error status, see WithRollbackStatus, or panics, the panic carrying
+// on to the recover middleware. It becomes a savepoint when the context
+// already holds a transaction, ignoring the isolation level and read-only
+// options. Callbacks registered with AfterCommit run once the transaction
+// commits.
+func TxMiddleware(opts ...TxOption) echo.MiddlewareFunc {
+
+	o := &txOptions{
+		rollbackStatus: defaultRollbackStatus,
+		retryBodyLimit: defaultRetryBodyLimit,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		run := func(c echo.Context) error {
+			err := RunInTx(c.Request().Context(), &o.tx, func(ctx context.Context) error {
+				c.SetRequest(c.Request().WithContext(ctx))
+
+				if err := next(c); err != nil {
+					return err
+				}
+
+				if o.rollbackStatus > 0 && c.Response().Status >= o.rollbackStatus {
+					return errRollbackStatus
+				}
+
+				return nil
+			})
+
+			if errors.Is(err, errRollbackStatus) {
+				// The response is already written
+				if err != errRollbackStatus {
+					slog.Error("could not roll back transaction", "error", err)
+				}
+
+				return nil
 			}
 
-			tx, err := db.BeginTx(ctx, nil)
+			return err
+		}
 
-			if err != nil {
-				return err
+		return func(c echo.Context) error {
+			if o.retry == nil || inTx(c.Request().Context()) {
+				return run(c)
 			}
 
-			ctx = WithContext(ctx, tx)
-			c.SetRequest(c.Request().WithContext(ctx))
+			req := c.Request()
+
+			var body []byte
+
+			if req.Body != nil {
+				var err error
+
+				body, err = io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, o.retryBodyLimit))
 
-			if err := next(c); err != nil {
-				txErr := tx.Rollback()
+				var tooLarge *http.MaxBytesError
 
-				if txErr != nil {
-					err = errors.Join(err, txErr)
+				if errors.As(err, &tooLarge) {
+					return echo.ErrStatusRequestEntityTooLarge
 				}
 
-				return err
-			}
+				if err != nil {
+					return err
+				}
 
-			if err := tx.Commit(); err != nil {
-				return err
+				req.Body.Close()
 			}
 
-			return nil
+			return retry(req.Context(), o.retry, func() (bool, error) {
+				// Start over from the request without the transaction
+				if req.Body != nil {
+					req.Body = io.NopCloser(bytes.NewReader(body))
+				}
+
+				c.SetRequest(req)
+
+				err := run(c)
+
+				return IsRetryable(err) && !c.Response().Committed, err
+			})
 		}
 	}
 }
@@ -0,0 +1,134 @@
+package database_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+// txOptionsDB records the options of the transactions it begins.
+type txOptionsDB struct {
+	*bun.DB
+	opts *sql.TxOptions
+}
+
+func (db *txOptionsDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (bun.Tx, error) {
+	db.opts = opts
+	return db.DB.BeginTx(ctx, opts)
+}
+
+func TestTxMiddleware(t *testing.T) {
+
+	db, purge, err := dbtest.SQLite("mo_middleware")
+	assert.NoError(t, err)
+
+	defer purge()
+
+	_, err = db.NewCreateTable().Model((*item)(nil)).Exec(context.Background())
+	assert.NoError(t, err)
+
+	count := func() int {
+		n, err := db.NewSelect().Model((*item)(nil)).Count(context.Background())
+		assert.NoError(t, err)
+
+		_, err = db.NewDelete().Model((*item)(nil)).Where("1 = 1").Exec(context.Background())
+		assert.NoError(t, err)
+
+		return n
+	}
+
+	handler := func(status int) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tx, err := database.FromContext(c.Request().Context())
+			assert.NoError(t, err)
+
+			_, err = tx.NewInsert().Model(&item{Source: c.Path()}).Exec(c.Request().Context())
+			assert.NoError(t, err)
+
+			if status < 0 {
+				panic("boom")
+			}
+
+			return c.NoContent(status)
+		}
+	}
+
+	// Answers panics with 500, echo/v4/middleware would pull in an old
+	// golang-jwt release
+	recoverPanics := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+
+			return next(c)
+		}
+	}
+
+	serve := func(opts []database.TxOption, status int) int {
+		e := echo.New()
+		e.Logger.SetOutput(io.Discard)
+		e.Use(recoverPanics, database.GlobalMiddleware(db), database.TxMiddleware(opts...))
+		e.POST("/", handler(status))
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+
+		return rec.Code
+	}
+
+	t.Run("Status", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, serve(nil, http.StatusCreated))
+		assert.Equal(t, 1, count())
+
+		assert.Equal(t, http.StatusConflict, serve(nil, http.StatusConflict))
+		assert.Equal(t, 0, count(), "error statuses should roll back")
+
+		assert.Equal(t, http.StatusConflict, serve([]database.TxOption{database.WithRollbackStatus(http.StatusInternalServerError)}, http.StatusConflict))
+		assert.Equal(t, 1, count())
+
+		assert.Equal(t, http.StatusInternalServerError, serve([]database.TxOption{database.WithRollbackStatus(0)}, http.StatusInternalServerError))
+		assert.Equal(t, 1, count())
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		assert.Equal(t, http.StatusInternalServerError, serve(nil, -1))
+		assert.Equal(t, 0, count(), "panics should roll back")
+
+		assert.Equal(t, http.StatusNoContent, serve(nil, http.StatusNoContent))
+		assert.Equal(t, 1, count(), "the connection should be usable after a panic")
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		recording := &txOptionsDB{DB: db}
+
+		e := echo.New()
+		e.Use(database.GlobalMiddleware(db))
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				c.SetRequest(c.Request().WithContext(database.WithContext(c.Request().Context(), recording)))
+				return next(c)
+			}
+		})
+
+		e.GET("/", func(c echo.Context) error {
+			return c.NoContent(http.StatusOK)
+		}, database.TxMiddleware(database.WithIsolation(sql.LevelSerializable), database.WithReadOnly()))
+
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		assert.Equal(t, &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}, recording.opts)
+	})
+}
@@ -0,0 +1,574 @@
+// Package migratecli implements the migrate command line, for apps to
+// embed with their own migrations or to run through cmd/mo.
+package migratecli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/uptrace/bun/migrate"
+)
+
+const usage = `Usage: migrate [-dir directory] <command> [arguments]
+
+Commands:
+  init                          create the database and migration tables
+  up [-dry-run] [-allow-destructive]
+                                apply pending migrations, or print their SQL
+  down [n]                      roll back the last n migration groups (default 1)
+  status [-json]                list migrations and whether they are applied
+  create <name> [-sql|-tx|-go]  create migration files (default -sql)
+  mark-applied [name...]        mark pending migrations as applied without running them
+  drift [-create name]          compare the models to the database, or create a
+                                SQL migration resolving the difference
+  unlock                        release the bun migration table lock left by a
+                                failed run, database locks end with their session
+
+The database is configured with the DB_* environment variables.
+`
+
+var (
+	ErrUnknownCommand    = errors.New("unknown command")
+	ErrMissingName       = errors.New("missing migration name")
+	ErrUnknownMigration  = errors.New("unknown migration")
+	ErrAlreadyApplied    = errors.New("migration already applied")
+	ErrConflictingFormat = errors.New("-go cannot be combined with -sql or -tx")
+	ErrNoModels          = errors.New("no models to compare")
+	ErrSchemaDrift       = errors.New("schema drift")
+)
+
+// Command runs migration commands.
+type Command struct {
+	// Migrations to run. When nil, SQL migrations are discovered in the
+	// directory given with -dir.
+	Migrations *migrate.Migrations
+	// Manager of the database to migrate. When nil, it is built from
+	// database.ParseConfig.
+	Manager database.SqlDatabaseManager
+	// Models compared to the database by drift.
+	Models []any
+	// GuardDestructive makes up refuse to run destructive migrations
+	// unless it is given -allow-destructive.
+	GuardDestructive bool
+
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// MigrationStatus is the JSON representation of a migration in status.
+type MigrationStatus struct {
+	Name       string     `json:"name"`
+	Comment    string     `json:"comment"`
+	Applied    bool       `json:"applied"`
+	GroupID    int64      `json:"groupId,omitempty"`
+	MigratedAt *time.Time `json:"migratedAt,omitempty"`
+}
+
+// Run parses args, without the program name, and runs the command.
+func (c *Command) Run(ctx context.Context, args []string) error {
+
+	fs := c.flagSet("migrate")
+	dir := fs.String("dir", database.DefaultDirectory, "migrations directory")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fmt.Fprint(c.stderr(), usage)
+		return ErrUnknownCommand
+	}
+
+	name, args := fs.Arg(0), fs.Args()[1:]
+
+	switch name {
+	case "create":
+		return c.create(ctx, *dir, args)
+	case "init", "up", "down", "status", "mark-applied", "drift", "unlock":
+	default:
+		fmt.Fprint(c.stderr(), usage)
+		return fmt.Errorf("%w: %s", ErrUnknownCommand, name)
+	}
+
+	manager, migrator, err := c.migrator(*dir)
+
+	if err != nil {
+		return err
+	}
+
+	if c.Manager == nil {
+		defer manager.Close()
+	}
+
+	switch name {
+	case "init":
+		return c.init(ctx, manager, migrator)
+	case "up":
+		return c.up(ctx, manager, migrator, args)
+	case "down":
+		return c.down(ctx, migrator, args)
+	case "status":
+		return c.status(ctx, migrator, args)
+	case "mark-applied":
+		return c.markApplied(ctx, migrator, args)
+	case "drift":
+		return c.drift(ctx, *dir, migrator, args)
+	default:
+		return c.unlock(ctx, migrator)
+	}
+}
+
+func (c *Command) init(ctx context.Context, manager database.SqlDatabaseManager, migrator *migrate.Migrator) error {
+
+	if err := database.InitMigrator(ctx, manager, migrator); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.stdout(), "migration tables created")
+
+	return nil
+}
+
+func (c *Command) up(ctx context.Context, manager database.SqlDatabaseManager, migrator *migrate.Migrator, args []string) error {
+
+	fs := c.flagSet("up")
+	dryRun := fs.Bool("dry-run", false, "print the SQL of pending migrations without running them")
+	allowDestructive := fs.Bool("allow-destructive", false, "run destructive migrations")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := database.InitMigrator(ctx, manager, migrator); err != nil {
+		return err
+	}
+
+	if *dryRun {
+		plans, err := database.DryRun(ctx, migrator)
+
+		if err != nil {
+			return err
+		}
+
+		return database.WritePlan(c.stdout(), plans)
+	}
+
+	var opts []database.MigrateOption
+
+	if c.GuardDestructive {
+		opts = append(opts, database.WithDestructiveGuard(*allowDestructive))
+	}
+
+	group, err := database.Migrate(ctx, migrator, opts...)
+
+	if errors.Is(err, database.ErrDestructiveMigration) {
+		return fmt.Errorf("%w, run with -allow-destructive to apply it", err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(group.Migrations) == 0 {
+		fmt.Fprintln(c.stdout(), "no pending migrations")
+		return nil
+	}
+
+	fmt.Fprintf(c.stdout(), "migrated to %s\n", group)
+
+	return nil
+}
+
+func (c *Command) down(ctx context.Context, migrator *migrate.Migrator, args []string) error {
+
+	n := 1
+
+	if len(args) > 0 {
+		parsed, err := strconv.Atoi(args[0])
+
+		if err != nil || parsed < 1 {
+			return fmt.Errorf("invalid number of groups: %s", args[0])
+		}
+
+		n = parsed
+	}
+
+	release, err := lockMigrations(ctx, migrator)
+
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	for range n {
+		group, err := migrator.Rollback(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		if group.IsZero() {
+			fmt.Fprintln(c.stdout(), "no groups to roll back")
+			return nil
+		}
+
+		fmt.Fprintf(c.stdout(), "rolled back %s\n", group)
+	}
+
+	return nil
+}
+
+func (c *Command) status(ctx context.Context, migrator *migrate.Migrator, args []string) error {
+
+	fs := c.flagSet("status")
+	asJSON := fs.Bool("json", false, "print status as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ms, err := migrator.MigrationsWithStatus(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(ms))
+
+	for _, m := range ms {
+		status := MigrationStatus{
+			Name:    m.Name,
+			Comment: m.Comment,
+			Applied: m.IsApplied(),
+			GroupID: m.GroupID,
+		}
+
+		if m.IsApplied() {
+			migratedAt := m.MigratedAt
+			status.MigratedAt = &migratedAt
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(c.stdout())
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(statuses)
+	}
+
+	w := tabwriter.NewWriter(c.stdout(), 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "MIGRATION\tSTATUS\tGROUP\tMIGRATED AT")
+
+	for _, s := range statuses {
+		if !s.Applied {
+			fmt.Fprintf(w, "%s_%s\tpending\t-\t-\n", s.Name, s.Comment)
+			continue
+		}
+
+		fmt.Fprintf(w, "%s_%s\tapplied\t%d\t%s\n", s.Name, s.Comment, s.GroupID, s.MigratedAt.Format(time.RFC3339))
+	}
+
+	return w.Flush()
+}
+
+func (c *Command) create(ctx context.Context, dir string, args []string) error {
+
+	fs := c.flagSet("create")
+	sql := fs.Bool("sql", false, "create up and down SQL migrations")
+	tx := fs.Bool("tx", false, "create transactional SQL migrations")
+	goFile := fs.Bool("go", false, "create a Go migration")
+
+	positional, err := parseInterspersed(fs, args)
+
+	if err != nil {
+		return err
+	}
+
+	if len(positional) == 0 {
+		return ErrMissingName
+	}
+
+	if *goFile && (*sql || *tx) {
+		return ErrConflictingFormat
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	migrations := migrate.NewMigrations(migrate.WithMigrationsDirectory(dir))
+	migrator := migrate.NewMigrator(nil, migrations)
+
+	var files []*migrate.MigrationFile
+
+	switch {
+	case *goFile:
+		file, err := migrator.CreateGoMigration(ctx, positional[0], migrate.WithPackageName(filepath.Base(dir)))
+
+		if err != nil {
+			return err
+		}
+
+		files = append(files, file)
+	case *tx:
+		files, err = migrator.CreateTxSQLMigrations(ctx, positional[0])
+	default:
+		files, err = migrator.CreateSQLMigrations(ctx, positional[0])
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		fmt.Fprintf(c.stdout(), "created %s\n", file.Path)
+	}
+
+	return nil
+}
+
+func (c *Command) markApplied(ctx context.Context, migrator *migrate.Migrator, args []string) error {
+
+	release, err := lockMigrations(ctx, migrator)
+
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	if len(args) == 0 {
+		group, err := migrator.Migrate(ctx, migrate.WithNopMigration())
+
+		if err != nil {
+			return err
+		}
+
+		if group.IsZero() {
+			fmt.Fprintln(c.stdout(), "no pending migrations")
+			return nil
+		}
+
+		fmt.Fprintf(c.stdout(), "marked %s as applied\n", group)
+
+		return nil
+	}
+
+	ms, err := migrator.MigrationsWithStatus(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	groupID := ms.LastGroupID() + 1
+
+	for _, name := range args {
+		var found *migrate.Migration
+
+		for i := range ms {
+			if ms[i].Name == name || ms[i].String() == name {
+				found = &ms[i]
+				break
+			}
+		}
+
+		if found == nil {
+			return fmt.Errorf("%w: %s", ErrUnknownMigration, name)
+		}
+
+		if found.IsApplied() {
+			return fmt.Errorf("%w: %s", ErrAlreadyApplied, name)
+		}
+
+		found.GroupID = groupID
+
+		if err := migrator.MarkApplied(ctx, found); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(c.stdout(), "marked %s as applied\n", found)
+	}
+
+	return nil
+}
+
+func (c *Command) drift(ctx context.Context, dir string, migrator *migrate.Migrator, args []string) error {
+
+	fs := c.flagSet("drift")
+	name := fs.String("create", "", "create a SQL migration resolving the drift")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(c.Models) == 0 {
+		return ErrNoModels
+	}
+
+	drifts, err := database.DetectDrift(ctx, migrator.DB(), c.Models...)
+
+	if err != nil {
+		return err
+	}
+
+	if len(drifts) == 0 {
+		fmt.Fprintln(c.stdout(), "no drift")
+		return nil
+	}
+
+	for _, d := range drifts {
+		fmt.Fprintln(c.stdout(), d)
+	}
+
+	if *name == "" {
+		return fmt.Errorf("%w: %d differences", ErrSchemaDrift, len(drifts))
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	files, err := migrate.NewMigrator(nil, migrate.NewMigrations(migrate.WithMigrationsDirectory(dir))).CreateSQLMigrations(ctx, *name)
+
+	if err != nil {
+		return err
+	}
+
+	up, down := database.DriftSQL(migrator.DB(), drifts)
+
+	for _, file := range files {
+		content := up
+
+		if strings.HasSuffix(file.Path, ".down.sql") {
+			content = down
+		}
+
+		if err := os.WriteFile(file.Path, []byte(content), 0o644); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(c.stdout(), "created %s\n", file.Path)
+	}
+
+	return nil
+}
+
+// unlock clears the lock of the bun migration table, taken by
+// migrate.Migrator.Lock. The database lock held by database.Migrate, down
+// and mark-applied ends with the session holding it and needs no unlocking.
+func (c *Command) unlock(ctx context.Context, migrator *migrate.Migrator) error {
+
+	if err := migrator.Unlock(ctx); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.stdout(), "migrations unlocked")
+
+	return nil
+}
+
+// lockMigrations holds the lock database.Migrate runs under, so that
+// commands changing the applied migrations do not race it.
+func lockMigrations(ctx context.Context, migrator *migrate.Migrator) (func() error, error) {
+
+	release, err := database.Lock(ctx, migrator.DB(), database.MigrationLockName, database.DefaultMigrationLockTimeout)
+
+	if err != nil {
+		return nil, fmt.Errorf("migration lock: %w", err)
+	}
+
+	return release, nil
+}
+
+func (c *Command) migrator(dir string) (database.SqlDatabaseManager, *migrate.Migrator, error) {
+
+	migrations := c.Migrations
+
+	if migrations == nil {
+		migrations = migrate.NewMigrations(migrate.WithMigrationsDirectory(dir))
+
+		if err := migrations.Discover(os.DirFS(dir)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	manager := c.Manager
+
+	if manager == nil {
+		cfg, err := database.ParseConfig()
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if manager, err = database.NewManager(cfg); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	db, err := manager.Bun()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return manager, migrate.NewMigrator(db, migrations), nil
+}
+
+func (c *Command) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr())
+
+	return fs
+}
+
+func (c *Command) stdout() io.Writer {
+	if c.Stdout == nil {
+		return os.Stdout
+	}
+
+	return c.Stdout
+}
+
+func (c *Command) stderr() io.Writer {
+	if c.Stderr == nil {
+		return os.Stderr
+	}
+
+	return c.Stderr
+}
+
+// parseInterspersed parses flags placed before or after positional
+// arguments and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
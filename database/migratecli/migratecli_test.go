@@ -0,0 +1,184 @@
+package migratecli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joelywz/mo/database/migratecli"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+func TestCommand(t *testing.T) {
+
+	dir := t.TempDir()
+	migrations := filepath.Join(dir, "migrations")
+
+	manager, purge, err := dbtest.SQLiteManager("mo_migratecli")
+	assert.NoError(t, err)
+
+	defer purge()
+
+	ctx := context.Background()
+
+	run := func(args ...string) (string, error) {
+		var stdout bytes.Buffer
+
+		cmd := &migratecli.Command{
+			Manager: manager,
+			Stdout:  &stdout,
+			Stderr:  &bytes.Buffer{},
+		}
+
+		err := cmd.Run(ctx, append([]string{"-dir", migrations}, args...))
+
+		return stdout.String(), err
+	}
+
+	status := func() []migratecli.MigrationStatus {
+		out, err := run("status", "-json")
+		assert.NoError(t, err)
+
+		var statuses []migratecli.MigrationStatus
+		assert.NoError(t, json.Unmarshal([]byte(out), &statuses))
+
+		return statuses
+	}
+
+	write := func(suffix string, content string) {
+		matches, err := filepath.Glob(filepath.Join(migrations, "*"+suffix))
+		assert.NoError(t, err)
+		assert.Len(t, matches, 1)
+		assert.NoError(t, os.WriteFile(matches[0], []byte(content), 0o644))
+	}
+
+	_, err = run("unknown")
+	assert.ErrorIs(t, err, migratecli.ErrUnknownCommand)
+
+	_, err = run("create")
+	assert.ErrorIs(t, err, migratecli.ErrMissingName)
+
+	_, err = run("create", "items", "-go", "-sql")
+	assert.ErrorIs(t, err, migratecli.ErrConflictingFormat)
+
+	// Create
+	out, err := run("create", "items", "-sql")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(out, "created"))
+
+	write("items.up.sql", "CREATE TABLE items (id INTEGER PRIMARY KEY);")
+	write("items.down.sql", "DROP TABLE items;")
+
+	_, err = run("init")
+	assert.NoError(t, err)
+
+	statuses := status()
+	assert.Len(t, statuses, 1)
+	assert.False(t, statuses[0].Applied)
+
+	out, err = run("status")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "pending")
+
+	// Dry run
+	out, err = run("up", "-dry-run")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "CREATE TABLE items (id INTEGER PRIMARY KEY);")
+	assert.False(t, status()[0].Applied, "dry run should not apply migrations")
+
+	// Up and down
+	_, err = run("up")
+	assert.NoError(t, err)
+	assert.True(t, status()[0].Applied)
+
+	db, err := manager.Bun()
+	assert.NoError(t, err)
+
+	_, err = db.ExecContext(ctx, "INSERT INTO items (id) VALUES (1)")
+	assert.NoError(t, err)
+
+	_, err = run("down")
+	assert.NoError(t, err)
+	assert.False(t, status()[0].Applied)
+
+	_, err = db.ExecContext(ctx, "INSERT INTO items (id) VALUES (1)")
+	assert.Error(t, err, "table should be dropped")
+
+	// Mark applied
+	_, err = run("mark-applied", "unknown")
+	assert.ErrorIs(t, err, migratecli.ErrUnknownMigration)
+
+	_, err = run("mark-applied")
+	assert.NoError(t, err)
+	assert.True(t, status()[0].Applied)
+
+	_, err = db.ExecContext(ctx, "INSERT INTO items (id) VALUES (1)")
+	assert.Error(t, err, "marked migration should not run")
+
+	// Unlock
+	_, err = db.ExecContext(ctx, "INSERT INTO bun_migration_locks (table_name) VALUES ('bun_migrations')")
+	assert.NoError(t, err)
+
+	_, err = run("up")
+	assert.NoError(t, err, "up should only hold the database lock")
+
+	_, err = run("unlock")
+	assert.NoError(t, err)
+
+	locks, err := db.NewSelect().Table("bun_migration_locks").Count(ctx)
+	assert.NoError(t, err)
+	assert.Zero(t, locks, "unlock should clear the migration table lock")
+
+	// Drift
+	_, err = db.ExecContext(ctx, "CREATE TABLE items (id INTEGER PRIMARY KEY)")
+	assert.NoError(t, err)
+
+	_, err = run("drift")
+	assert.ErrorIs(t, err, migratecli.ErrNoModels)
+
+	drift := func(args ...string) (string, error) {
+		var stdout bytes.Buffer
+
+		cmd := &migratecli.Command{
+			Manager: manager,
+			Models:  []any{(*item)(nil)},
+			Stdout:  &stdout,
+			Stderr:  &bytes.Buffer{},
+		}
+
+		err := cmd.Run(ctx, append([]string{"-dir", migrations, "drift"}, args...))
+
+		return stdout.String(), err
+	}
+
+	out, err = drift()
+	assert.ErrorIs(t, err, migratecli.ErrSchemaDrift)
+	assert.Contains(t, out, "missing column: items.name")
+
+	// Migrations are named after the second they are created in
+	time.Sleep(time.Second)
+
+	out, err = drift("-create", "item_name")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(out, "created"))
+
+	_, err = run("up")
+	assert.NoError(t, err)
+
+	out, err = drift()
+	assert.NoError(t, err)
+	assert.Equal(t, "no drift\n", out)
+}
+
+type item struct {
+	bun.BaseModel `bun:"items"`
+	ID            int64  `bun:"id,pk"`
+	Name          string `bun:"name,type:varchar(64)"`
+}
@@ -2,8 +2,16 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
 
 	mysqlerrnum "github.com/bombsimon/mysql-error-numbers/v2"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/driver/pgdriver"
 	"github.com/uptrace/bun/migrate"
 )
 
@@ -25,7 +33,83 @@ func CreateMigration(name string) error {
 	return nil
 }
 
-func MigrateUp(manager SqlDatabaseManager, migrations *migrate.Migrations) error {
+const (
+	// MigrationLockName is the Lock held by Migrate, for tools changing the
+	// applied migrations to hold while they do.
+	MigrationLockName = "bun_migrations"
+	// DefaultMigrationLockTimeout is how long Migrate waits for
+	// MigrationLockName unless given WithLockTimeout.
+	DefaultMigrationLockTimeout = 5 * time.Minute
+
+	defaultMigrationTable = "bun_migrations"
+)
+
+type MigrateOption func(o *migrateOptions)
+
+type migrateOptions struct {
+	dryRun           io.Writer
+	guard            bool
+	allowDestructive bool
+	lockTimeout      time.Duration
+	table            string
+}
+
+// WithDryRun writes the SQL of the pending migrations to w instead of
+// running them. Only the migration tables are created.
+func WithDryRun(w io.Writer) MigrateOption {
+	return func(o *migrateOptions) {
+		o.dryRun = w
+	}
+}
+
+// WithDestructiveGuard plans the pending migrations before running them
+// and fails with ErrDestructiveMigration if one of them is destructive,
+// unless allow is set.
+func WithDestructiveGuard(allow bool) MigrateOption {
+	return func(o *migrateOptions) {
+		o.guard = true
+		o.allowDestructive = allow
+	}
+}
+
+// WithLockTimeout waits up to d for another process to finish migrating,
+// 5 minutes by default.
+func WithLockTimeout(d time.Duration) MigrateOption {
+	return func(o *migrateOptions) {
+		o.lockTimeout = d
+	}
+}
+
+// WithMigrationTable names the table recording applied migrations, for a
+// migrator created with migrate.WithTableName(table). Migrate cannot read
+// the name back from the migrator, bun_migrations is assumed otherwise.
+func WithMigrationTable(table string) MigrateOption {
+	return func(o *migrateOptions) {
+		o.table = table
+	}
+}
+
+func newMigrateOptions(opts []MigrateOption) *migrateOptions {
+
+	o := &migrateOptions{
+		lockTimeout: DefaultMigrationLockTimeout,
+		table:       defaultMigrationTable,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// MigrateUp creates the database and the migration tables if needed, then
+// runs the pending migrations with Migrate.
+func MigrateUp(manager SqlDatabaseManager, migrations *migrate.Migrations, opts ...MigrateOption) error {
+
+	o := newMigrateOptions(opts)
+
+	ctx := context.Background()
 
 	db, err := manager.Bun()
 
@@ -33,10 +117,141 @@ func MigrateUp(manager SqlDatabaseManager, migrations *migrate.Migrations) error
 		return err
 	}
 
-	migrator := migrate.NewMigrator(db, migrations)
+	migrator := migrate.NewMigrator(db, migrations, migrate.WithTableName(o.table))
+
+	if err := InitMigrator(ctx, manager, migrator); err != nil {
+		return err
+	}
+
+	if o.dryRun != nil {
+		plans, err := DryRun(ctx, migrator)
 
-	if err := migrator.Init(context.Background()); err != nil {
-		if mysqlerrnum.FromError(err) != mysqlerrnum.ErrBadDbError {
+		if err != nil {
+			return err
+		}
+
+		return WritePlan(o.dryRun, plans)
+	}
+
+	_, err = Migrate(ctx, migrator, opts...)
+
+	return err
+}
+
+// Migrate runs the pending migrations of migrator as a new group, holding
+// a database lock so that processes starting together run them once. On
+// Postgres and SQLite, where DDL is transactional, each migration runs in
+// its own transaction along with marking it applied. On MySQL a migration
+// is marked applied once it succeeds.
+func Migrate(ctx context.Context, migrator *migrate.Migrator, opts ...MigrateOption) (*migrate.MigrationGroup, error) {
+
+	o := newMigrateOptions(opts)
+	db := migrator.DB()
+
+	start := time.Now()
+
+	release, err := Lock(ctx, db, MigrationLockName, o.lockTimeout)
+
+	if err != nil {
+		return nil, fmt.Errorf("migration lock: %w", err)
+	}
+
+	defer release()
+
+	if wait := time.Since(start); wait > time.Second {
+		slog.Info("acquired migration lock", "wait", wait)
+	}
+
+	if o.guard {
+		plans, err := DryRun(ctx, migrator)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := CheckDestructive(plans); err != nil {
+			if !o.allowDestructive {
+				return nil, err
+			}
+
+			slog.Warn("running destructive migrations", "error", err)
+		}
+	}
+
+	ms, err := migrator.MigrationsWithStatus(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	group := &migrate.MigrationGroup{ID: ms.LastGroupID() + 1}
+	pending := ms.Unapplied()
+
+	if len(pending) == 0 {
+		slog.Info("no pending migrations")
+		return group, nil
+	}
+
+	for i := range pending {
+		m := &pending[i]
+		m.GroupID = group.ID
+
+		migrationStart := time.Now()
+
+		slog.Info("applying migration", "migration", m.String(), "group", group.ID)
+
+		if err := applyMigration(ctx, migrator, o.table, m); err != nil {
+			slog.Error("migration failed", "migration", m.String(), "duration", time.Since(migrationStart), "error", err)
+			return group, fmt.Errorf("migration %s: %w", m, err)
+		}
+
+		group.Migrations = append(group.Migrations, *m)
+
+		slog.Info("applied migration", "migration", m.String(), "duration", time.Since(migrationStart))
+	}
+
+	slog.Info("applied migrations", "group", group.ID, "count", len(group.Migrations), "duration", time.Since(start))
+
+	return group, nil
+}
+
+// applyMigration runs m and marks it applied in table, in a transaction
+// where the dialect has transactional DDL.
+func applyMigration(ctx context.Context, migrator *migrate.Migrator, table string, m *migrate.Migration) error {
+
+	db := migrator.DB()
+
+	if db.Dialect().Name() == dialect.MySQL {
+		if m.Up != nil {
+			if err := m.Up(ctx, db); err != nil {
+				return err
+			}
+		}
+
+		return migrator.MarkApplied(ctx, m)
+	}
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+
+		txDB := newTxDB(db, tx)
+		defer txDB.Close()
+
+		if m.Up != nil {
+			if err := m.Up(ctx, txDB); err != nil {
+				return err
+			}
+		}
+
+		return migrate.NewMigrator(txDB, migrate.NewMigrations(), migrate.WithTableName(table)).MarkApplied(ctx, m)
+	})
+}
+
+// InitMigrator creates the migration tables of migrator, creating the
+// database of manager first if it does not exist yet.
+func InitMigrator(ctx context.Context, manager SqlDatabaseManager, migrator *migrate.Migrator) error {
+
+	if err := migrator.Init(ctx); err != nil {
+		if !isUnknownDatabase(err) {
 			return err
 		}
 
@@ -44,12 +259,24 @@ func MigrateUp(manager SqlDatabaseManager, migrations *migrate.Migrations) error
 			return err
 		}
 
-		if err := migrator.Init(context.Background()); err != nil {
+		if err := migrator.Init(ctx); err != nil {
 			return err
 		}
 	}
 
-	_, err = migrator.Migrate(context.Background())
+	return nil
+}
 
-	return err
+// isUnknownDatabase reports whether err was returned because the database
+// does not exist yet.
+func isUnknownDatabase(err error) bool {
+
+	if mysqlerrnum.FromError(err) == mysqlerrnum.ErrBadDbError {
+		return true
+	}
+
+	var pgErr pgdriver.Error
+
+	// SQLSTATE invalid_catalog_name
+	return errors.As(err, &pgErr) && pgErr.Field('C') == "3D000"
 }
@@ -0,0 +1,169 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+func TestMigrate(t *testing.T) {
+
+	manager, purge, err := dbtest.SQLiteManager("mo_migration")
+	assert.NoError(t, err)
+
+	defer purge()
+
+	var logs bytes.Buffer
+
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	ctx := context.Background()
+
+	db, err := manager.Bun()
+	assert.NoError(t, err)
+
+	exec := func(query string) migrate.MigrationFunc {
+		return func(ctx context.Context, db *bun.DB) error {
+			_, err := db.ExecContext(ctx, query)
+			return err
+		}
+	}
+
+	migrations := migrate.NewMigrations()
+
+	migrations.Add(migrate.Migration{Name: "20240101000000", Comment: "create_a", Up: exec("CREATE TABLE a (id INTEGER PRIMARY KEY)")})
+	migrations.Add(migrate.Migration{
+		Name:    "20240102000000",
+		Comment: "create_b",
+		Up: func(ctx context.Context, db *bun.DB) error {
+			if _, err := db.ExecContext(ctx, "CREATE TABLE b (id INTEGER PRIMARY KEY)"); err != nil {
+				return err
+			}
+
+			return errors.New("boom")
+		},
+	})
+
+	err = database.MigrateUp(manager, migrations)
+	assert.ErrorContains(t, err, "migration 20240102000000_create_b: boom")
+
+	ms, err := migrate.NewMigrator(db, migrations).MigrationsWithStatus(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ms[0].IsApplied())
+	assert.False(t, ms[1].IsApplied(), "failed migration should not be marked applied")
+
+	_, err = db.ExecContext(ctx, "SELECT * FROM b")
+	assert.Error(t, err, "failed migration should be rolled back")
+
+	assert.Contains(t, logs.String(), `msg="applied migration" migration=20240101000000_create_a duration=`)
+	assert.Contains(t, logs.String(), `msg="migration failed" migration=20240102000000_create_b`)
+}
+
+func TestMigrateTableName(t *testing.T) {
+
+	db, purge, err := dbtest.SQLite("mo_migrate_table")
+	assert.NoError(t, err)
+
+	defer purge()
+
+	ctx := context.Background()
+
+	migrations := migrate.NewMigrations()
+	migrations.Add(migrate.Migration{Name: "20240101000000", Comment: "create_a", Up: func(ctx context.Context, db *bun.DB) error {
+		_, err := db.ExecContext(ctx, "CREATE TABLE a (id INTEGER PRIMARY KEY)")
+		return err
+	}})
+
+	migrator := migrate.NewMigrator(db, migrations, migrate.WithTableName("app_migrations"))
+	assert.NoError(t, migrator.Init(ctx))
+
+	_, err = database.Migrate(ctx, migrator, database.WithMigrationTable("app_migrations"))
+	assert.NoError(t, err)
+
+	ms, err := migrator.MigrationsWithStatus(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ms[0].IsApplied(), "migration should be marked applied in the table of the migrator")
+
+	_, err = db.ExecContext(ctx, "SELECT * FROM bun_migrations")
+	assert.Error(t, err, "default migration table should not be created")
+}
+
+// TestMigrateConcurrently runs migrations from several processes at once,
+// which relies on the MySQL named lock.
+func TestMigrateConcurrently(t *testing.T) {
+
+	if os.Getenv("DB_DIALECT") == "sqlite" {
+		t.Skip("SQLite has no named locks")
+	}
+
+	db, purge, err := dbtest.MySQL("mo_migrate")
+
+	if err != nil {
+		t.Fatalf("Could not start database: %s", err)
+	}
+
+	defer purge()
+
+	ctx := context.Background()
+
+	var runs [2]atomic.Int32
+
+	migrations := migrate.NewMigrations()
+
+	for i, table := range []string{"a", "b"} {
+		migrations.Add(migrate.Migration{
+			Name:    fmt.Sprintf("2024010%d000000", i+1),
+			Comment: "create_" + table,
+			Up: func(ctx context.Context, db *bun.DB) error {
+				runs[i].Add(1)
+
+				_, err := db.ExecContext(ctx, "CREATE TABLE "+table+" (id INT PRIMARY KEY)")
+				return err
+			},
+		})
+	}
+
+	assert.NoError(t, migrate.NewMigrator(db, migrations).Init(ctx))
+
+	var wg sync.WaitGroup
+
+	errs := make([]error, 5)
+
+	for i := range errs {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, errs[i] = database.Migrate(ctx, migrate.NewMigrator(db, migrations))
+		}()
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+
+	for i := range runs {
+		assert.Equal(t, int32(1), runs[i].Load(), "each migration should run once")
+	}
+
+	ms, err := migrate.NewMigrator(db, migrations).MigrationsWithStatus(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, ms.Applied(), 2)
+	assert.Equal(t, ms[0].GroupID, ms[1].GroupID, "migrations should be applied as one group")
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/uptrace/bun"
+	"go.uber.org/fx"
+)
+
+// Module provides the manager for Config, its shared *bun.DB and a Router
+// over the replicas in Config.Replicas, and closes the connection pools
+// when the app stops.
+var Module = fx.Module("database",
+	fx.Provide(ParseConfig, NewManager, Bun, OpenRouter),
+	fx.Invoke(Run, RunRouter),
+)
+
+// Bun returns the shared *bun.DB of manager.
+func Bun(manager SqlDatabaseManager) (*bun.DB, error) {
+	return manager.Bun()
+}
+
+// Run closes the connection pool of manager with the fx lifecycle.
+func Run(lc fx.Lifecycle, manager SqlDatabaseManager) {
+	lc.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			return manager.Close()
+		},
+	})
+}
+
+// RunRouter starts and stops the health checks of the router and closes
+// its replicas with the fx lifecycle.
+func RunRouter(lc fx.Lifecycle, r *Router) {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			r.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return errors.Join(r.Stop(ctx), r.Close())
+		},
+	})
+}
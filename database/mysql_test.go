@@ -0,0 +1,102 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/joelywz/mo/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMySQLConfig(t *testing.T) {
+
+	config := func(cfg *database.Config) *mysql.Config {
+		manager, err := database.NewMySQLManager(cfg)
+		assert.NoError(t, err)
+
+		c, err := manager.Config()
+		assert.NoError(t, err)
+
+		return c
+	}
+
+	t.Run("Fields", func(t *testing.T) {
+		c := config(&database.Config{
+			Host: "db",
+			Port: "3307",
+			User: "root",
+			Pass: "p@ss/word",
+			Name: "mo",
+		})
+
+		assert.Equal(t, "p@ss/word", c.Passwd)
+		assert.Equal(t, "tcp", c.Net)
+		assert.Equal(t, "db:3307", c.Addr)
+		assert.Equal(t, "mo", c.DBName)
+		assert.Nil(t, c.TLS)
+	})
+
+	t.Run("DefaultPort", func(t *testing.T) {
+		c := config(&database.Config{
+			Host: "db",
+			Name: "mo",
+		})
+
+		assert.Equal(t, "db:3306", c.Addr)
+	})
+
+	t.Run("Socket", func(t *testing.T) {
+		c := config(&database.Config{
+			Socket: "/run/mysqld/mysqld.sock",
+			Name:   "mo",
+		})
+
+		assert.Equal(t, "unix", c.Net)
+		assert.Equal(t, "/run/mysqld/mysqld.sock", c.Addr)
+	})
+
+	t.Run("URL", func(t *testing.T) {
+		c := config(&database.Config{
+			URL: "mysql://user:p%40ss%2Fword@db/mo?parseTime=true",
+		})
+
+		assert.Equal(t, "user", c.User)
+		assert.Equal(t, "p@ss/word", c.Passwd)
+		assert.Equal(t, "db:3306", c.Addr)
+		assert.Equal(t, "mo", c.DBName)
+		assert.True(t, c.ParseTime)
+	})
+
+	t.Run("DSN", func(t *testing.T) {
+		c := config(&database.Config{
+			URL: "user:pass@tcp(db:3306)/mo",
+		})
+
+		assert.Equal(t, "db:3306", c.Addr)
+		assert.Equal(t, "mo", c.DBName)
+	})
+
+	t.Run("Params", func(t *testing.T) {
+		c := config(&database.Config{
+			URL:    "user:pass@tcp(db:3306)/mo?timeout=1s",
+			Params: map[string]string{"parseTime": "true", "loc": "Local"},
+			TLS:    true,
+		})
+
+		assert.True(t, c.ParseTime)
+		assert.Equal(t, time.Local, c.Loc)
+		assert.Equal(t, time.Second, c.Timeout)
+		assert.NotNil(t, c.TLS)
+	})
+
+	t.Run("MissingCA", func(t *testing.T) {
+		manager, err := database.NewMySQLManager(&database.Config{
+			TLSCA: "testdata/missing.pem",
+		})
+		assert.NoError(t, err)
+
+		_, err = manager.Config()
+		assert.Error(t, err)
+	})
+}
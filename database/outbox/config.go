@@ -0,0 +1,23 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/caarlos0/env/v11"
+)
+
+type Config struct {
+	PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" envDefault:"1s"`
+	BatchSize    int           `env:"OUTBOX_BATCH_SIZE" envDefault:"100"`
+	MaxAttempts  int           `env:"OUTBOX_MAX_ATTEMPTS" envDefault:"10"`
+	BaseBackoff  time.Duration `env:"OUTBOX_BASE_BACKOFF" envDefault:"1s"`
+	MaxBackoff   time.Duration `env:"OUTBOX_MAX_BACKOFF" envDefault:"1h"`
+	// ClaimTimeout delivers claimed messages again when they have not been
+	// delivered in time, for instance because their dispatcher crashed.
+	ClaimTimeout time.Duration `env:"OUTBOX_CLAIM_TIMEOUT" envDefault:"5m"`
+}
+
+func ParseConfig() (*Config, error) {
+	cfg, err := env.ParseAs[Config]()
+	return &cfg, err
+}
@@ -0,0 +1,265 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/uptrace/bun"
+	"go.uber.org/fx"
+)
+
+var (
+	ErrNoHandler = errors.New("no outbox handler for topic")
+)
+
+// Handler delivers a message. Returning an error schedules a retry.
+type Handler func(ctx context.Context, msg *Message) error
+
+// Dispatcher polls the outbox and delivers pending messages to the handler
+// registered for their topic. Messages are claimed with SELECT ... FOR
+// UPDATE SKIP LOCKED, so several dispatchers can run side by side, and
+// delivered once the claim committed. Delivery is at least once.
+type Dispatcher struct {
+	db  *bun.DB
+	cfg *Config
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewDispatcher(db *bun.DB, cfg *Config) *Dispatcher {
+	return &Dispatcher{
+		db:       db,
+		cfg:      cfg,
+		handlers: map[string]Handler{},
+	}
+}
+
+// Handle registers the handler for a topic, replacing any previous one.
+func (d *Dispatcher) Handle(topic string, handler Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.handlers[topic] = handler
+}
+
+// Start polls the outbox in the background until Stop is called.
+func (d *Dispatcher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d.cancel = cancel
+	d.done = make(chan struct{})
+
+	go d.run(ctx)
+}
+
+// Stop stops polling and waits for the batch in progress, or until ctx is
+// done.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	if d.cancel == nil {
+		return nil
+	}
+
+	d.cancel()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) run(ctx context.Context) {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := d.DispatchOnce(ctx)
+
+		if err != nil && ctx.Err() == nil {
+			slog.Error("dispatching outbox", "error", err)
+		}
+
+		// Keep draining while batches come back full
+		if err == nil && n == d.cfg.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce delivers a single batch of due messages and returns how many
+// were processed. Only messages with a registered handler are claimed,
+// others stay pending untouched. Once ctx is done the rest of the batch is
+// left unattempted, to be claimed again after Config.ClaimTimeout.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+
+	msgs, err := d.claim(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+
+	for i := range msgs {
+		if err := ctx.Err(); err != nil {
+			return i, errors.Join(append(errs, err)...)
+		}
+
+		errs = append(errs, d.deliver(ctx, &msgs[i]))
+	}
+
+	return len(msgs), errors.Join(errs...)
+}
+
+// claim locks a batch of due messages and pushes their availability back by
+// Config.ClaimTimeout, so that no other dispatcher picks them up while they
+// are delivered. The locks are released before any handler runs.
+func (d *Dispatcher) claim(ctx context.Context) ([]Message, error) {
+
+	topics := d.topics()
+
+	if len(topics) == 0 {
+		return nil, nil
+	}
+
+	var msgs []Message
+
+	err := d.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+
+		err := tx.NewSelect().
+			Model(&msgs).
+			Where("status = ?", StatusPending).
+			Where("topic IN (?)", bun.In(topics)).
+			Where("available_at <= ?", time.Now()).
+			Order("available_at", "id").
+			Limit(d.cfg.BatchSize).
+			Apply(database.SkipLocked).
+			Scan(ctx)
+
+		if err != nil || len(msgs) == 0 {
+			return err
+		}
+
+		ids := make([]string, 0, len(msgs))
+		claimedUntil := time.Now().Add(d.cfg.ClaimTimeout)
+
+		for i := range msgs {
+			ids = append(ids, msgs[i].ID)
+
+			msgs[i].Attempts++
+			msgs[i].AvailableAt = claimedUntil
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*Message)(nil)).
+			Where("id IN (?)", bun.In(ids)).
+			Set("attempts = attempts + 1").
+			Set("available_at = ?", claimedUntil).
+			Exec(ctx)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return msgs, nil
+}
+
+func (d *Dispatcher) topics() []string {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	topics := make([]string, 0, len(d.handlers))
+
+	for topic := range d.handlers {
+		topics = append(topics, topic)
+	}
+
+	return topics
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, msg *Message) error {
+
+	d.mu.RLock()
+	handler, ok := d.handlers[msg.Topic]
+	d.mu.RUnlock()
+
+	var err error
+
+	if ok {
+		err = d.safeHandle(database.WithContext(ctx, d.db), handler, msg)
+	} else {
+		err = fmt.Errorf("%w: %s", ErrNoHandler, msg.Topic)
+	}
+
+	now := time.Now()
+
+	if err == nil {
+		msg.Status = StatusDelivered
+		msg.DeliveredAt = &now
+	} else {
+		msg.LastError = err.Error()
+		msg.AvailableAt = now.Add(database.Backoff(msg.Attempts, d.cfg.BaseBackoff, d.cfg.MaxBackoff))
+
+		if msg.Attempts >= d.cfg.MaxAttempts {
+			msg.Status = StatusDead
+
+			slog.Warn("outbox message dead lettered", "id", msg.ID, "topic", msg.Topic, "error", err)
+		}
+	}
+
+	// Record the outcome even when the dispatcher is shutting down, unless
+	// the claim expired and another dispatcher claimed the message again
+	_, err = d.db.NewUpdate().
+		Model(msg).
+		Column("status", "last_error", "available_at", "delivered_at").
+		WherePK().
+		Where("status = ?", StatusPending).
+		Where("attempts = ?", msg.Attempts).
+		Exec(context.WithoutCancel(ctx))
+
+	return err
+}
+
+func (d *Dispatcher) safeHandle(ctx context.Context, handler Handler, msg *Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("outbox handler panic: %v", r)
+		}
+	}()
+
+	return handler(ctx, msg)
+}
+
+// Run starts the dispatcher with the fx lifecycle.
+func Run(lc fx.Lifecycle, d *Dispatcher) {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			d.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return d.Stop(ctx)
+		},
+	})
+}
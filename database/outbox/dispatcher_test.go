@@ -0,0 +1,253 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/database/outbox"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+var db *bun.DB
+
+func TestMain(m *testing.M) {
+	var (
+		purge func() error
+		err   error
+	)
+
+	db, purge, err = dbtest.Open("mo_outbox")
+
+	if err != nil {
+		log.Fatalf("Could not start database: %s", err)
+	}
+
+	// Migrate database
+	migrations := migrate.NewMigrations()
+	outbox.RegisterMigrations(migrations)
+
+	migrator := migrate.NewMigrator(db, migrations)
+
+	if err := migrator.Init(context.Background()); err != nil {
+		log.Fatalf("Could not init migrations: %s", err)
+	}
+
+	if _, err := migrator.Migrate(context.Background()); err != nil {
+		log.Fatalf("Could not migrate: %s", err)
+	}
+
+	code := m.Run()
+
+	if err := purge(); err != nil {
+		log.Fatalf("Could not purge resource: %s", err)
+	}
+
+	os.Exit(code)
+}
+
+func TestMigrations(t *testing.T) {
+
+	drifts, err := database.DetectDrift(context.Background(), db, outbox.Models()...)
+
+	assert.NoError(t, err)
+	assert.Empty(t, drifts, "migrations should match the models")
+}
+
+type signup struct {
+	Email string `json:"email"`
+}
+
+func TestDispatcher(t *testing.T) {
+
+	dispatcher := outbox.NewDispatcher(db, &outbox.Config{
+		PollInterval: 50 * time.Millisecond,
+		BatchSize:    10,
+		MaxAttempts:  2,
+		BaseBackoff:  time.Hour,
+		MaxBackoff:   time.Hour,
+		ClaimTimeout: time.Minute,
+	})
+
+	ctx := context.Background()
+	ctx = database.WithContext(ctx, db)
+
+	message := func(topic string) *outbox.Message {
+		var msg outbox.Message
+
+		err := db.NewSelect().Model(&msg).Where("topic = ?", topic).Scan(ctx)
+		assert.NoError(t, err)
+
+		return &msg
+	}
+
+	// Make a message due again, skipping its backoff
+	due := func(topic string) {
+		_, err := db.NewUpdate().
+			Model((*outbox.Message)(nil)).
+			Where("topic = ?", topic).
+			Set("available_at = ?", time.Now().Add(-time.Second)).
+			Exec(ctx)
+		assert.NoError(t, err)
+	}
+
+	t.Run("Dispatch", func(t *testing.T) {
+		received := make(chan signup, 1)
+
+		dispatcher.Handle("user.signup", func(ctx context.Context, msg *outbox.Message) error {
+			var payload signup
+
+			if err := msg.Decode(&payload); err != nil {
+				return err
+			}
+
+			received <- payload
+
+			// The claim is committed, handlers are free to write
+			return outbox.Publish(ctx, "user.welcome", payload)
+		})
+
+		assert.NoError(t, outbox.Publish(ctx, "user.signup", signup{Email: "test@example.com"}))
+		assert.NoError(t, outbox.Publish(ctx, "user.unhandled", nil))
+
+		n, err := dispatcher.DispatchOnce(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n, "only messages with a handler should be claimed")
+		assert.Equal(t, signup{Email: "test@example.com"}, <-received)
+
+		msg := message("user.signup")
+		assert.Equal(t, outbox.StatusDelivered, msg.Status)
+		assert.Equal(t, 1, msg.Attempts)
+		assert.NotNil(t, msg.DeliveredAt)
+
+		unhandled := message("user.unhandled")
+		assert.Equal(t, outbox.StatusPending, unhandled.Status)
+		assert.Equal(t, 0, unhandled.Attempts, "messages without handler should be left untouched")
+
+		welcome := message("user.welcome")
+		assert.Equal(t, outbox.StatusPending, welcome.Status)
+	})
+
+	t.Run("Retry", func(t *testing.T) {
+		dispatcher.Handle("user.flaky", func(ctx context.Context, msg *outbox.Message) error {
+			return errors.New("unavailable")
+		})
+
+		assert.NoError(t, outbox.Publish(ctx, "user.flaky", nil))
+
+		n, err := dispatcher.DispatchOnce(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+
+		msg := message("user.flaky")
+		assert.Equal(t, outbox.StatusPending, msg.Status)
+		assert.Equal(t, 1, msg.Attempts)
+		assert.Equal(t, "unavailable", msg.LastError)
+		assert.True(t, msg.AvailableAt.After(time.Now().Add(30*time.Minute)), "failed messages should back off")
+
+		n, err = dispatcher.DispatchOnce(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n, "messages should not be retried before their backoff")
+	})
+
+	t.Run("DeadLetter", func(t *testing.T) {
+		due("user.flaky")
+
+		_, err := dispatcher.DispatchOnce(ctx)
+		assert.NoError(t, err)
+
+		msg := message("user.flaky")
+		assert.Equal(t, outbox.StatusDead, msg.Status)
+		assert.Equal(t, 2, msg.Attempts)
+
+		due("user.flaky")
+
+		n, err := dispatcher.DispatchOnce(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n, "dead messages should not be dispatched")
+	})
+
+	t.Run("Redrive", func(t *testing.T) {
+		msg := message("user.flaky")
+
+		assert.NoError(t, outbox.Redrive(ctx, msg.ID))
+		assert.ErrorIs(t, outbox.Redrive(ctx, msg.ID), outbox.ErrMessageNotFound, "only dead messages should be redriven")
+
+		msg = message("user.flaky")
+		assert.Equal(t, outbox.StatusPending, msg.Status)
+		assert.Equal(t, 0, msg.Attempts)
+
+		dispatcher.Handle("user.flaky", func(ctx context.Context, msg *outbox.Message) error {
+			return nil
+		})
+
+		n, err := dispatcher.DispatchOnce(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, outbox.StatusDelivered, message("user.flaky").Status)
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		dispatcher.Handle("user.panic", func(ctx context.Context, msg *outbox.Message) error {
+			panic("boom")
+		})
+
+		assert.NoError(t, outbox.Publish(ctx, "user.panic", nil))
+
+		_, err := dispatcher.DispatchOnce(ctx)
+		assert.NoError(t, err)
+		assert.Contains(t, message("user.panic").LastError, "boom")
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		cancelCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var calls int
+
+		dispatcher.Handle("user.cancel", func(ctx context.Context, msg *outbox.Message) error {
+			calls++
+			cancel()
+
+			return nil
+		})
+
+		assert.NoError(t, outbox.Publish(ctx, "user.cancel", nil))
+		assert.NoError(t, outbox.Publish(ctx, "user.cancel", nil))
+
+		n, err := dispatcher.DispatchOnce(cancelCtx)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, n, "the rest of the batch should be left once cancelled")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Start", func(t *testing.T) {
+		received := make(chan struct{}, 1)
+
+		dispatcher.Handle("user.welcome", func(ctx context.Context, msg *outbox.Message) error {
+			received <- struct{}{}
+			return nil
+		})
+
+		dispatcher.Start()
+
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("message not dispatched")
+		}
+
+		assert.NoError(t, dispatcher.Stop(context.Background()))
+
+		assert.Eventually(t, func() bool {
+			return message("user.welcome").Status == outbox.StatusDelivered
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+}
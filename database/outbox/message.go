@@ -0,0 +1,64 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+var (
+	_ bun.BeforeAppendModelHook = (*Message)(nil)
+	_ database.IndexedModel     = (*Message)(nil)
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "PENDING"
+	StatusDelivered Status = "DELIVERED"
+	// StatusDead marks messages that exhausted their delivery attempts.
+	StatusDead Status = "DEAD"
+)
+
+type Message struct {
+	bun.BaseModel `bun:"outbox"`
+	ID            string     `bun:"id,pk,notnull,type:varchar(32)"`
+	Topic         string     `bun:"topic,notnull,type:varchar(128)"`
+	Payload       string     `bun:"payload,notnull,type:text"`
+	Status        Status     `bun:"status,notnull,type:varchar(16)"`
+	Attempts      int        `bun:"attempts,notnull"`
+	LastError     string     `bun:"last_error,notnull,type:text"`
+	AvailableAt   time.Time  `bun:"available_at,notnull"`
+	DeliveredAt   *time.Time `bun:"delivered_at"`
+	CreatedAt     time.Time  `bun:"created_at,notnull"`
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (m *Message) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		m.CreatedAt = time.Now()
+
+		if m.AvailableAt.IsZero() {
+			m.AvailableAt = m.CreatedAt
+		}
+	}
+
+	return nil
+}
+
+// Indexes implements database.IndexedModel.
+func (m *Message) Indexes() []database.Index {
+	return []database.Index{
+		{Name: "outbox_status_available_at_idx", Columns: []string{"status", "available_at"}},
+	}
+}
+
+// Decode unmarshals the JSON payload of the message into v.
+func (m *Message) Decode(v any) error {
+	return json.Unmarshal([]byte(m.Payload), v)
+}
@@ -0,0 +1,13 @@
+CREATE TABLE outbox (
+  id VARCHAR(32) NOT NULL,
+  topic VARCHAR(128) NOT NULL,
+  payload TEXT NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  attempts BIGINT NOT NULL,
+  last_error TEXT NOT NULL,
+  available_at DATETIME NOT NULL,
+  delivered_at DATETIME,
+  created_at DATETIME NOT NULL,
+  PRIMARY KEY (id),
+  INDEX outbox_status_available_at_idx (status, available_at)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE outbox (
+  id VARCHAR(32) NOT NULL,
+  topic VARCHAR(128) NOT NULL,
+  payload TEXT NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  attempts BIGINT NOT NULL,
+  last_error TEXT NOT NULL,
+  available_at TIMESTAMPTZ NOT NULL,
+  delivered_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (id)
+);
+
+--bun:split
+
+CREATE INDEX outbox_status_available_at_idx ON outbox (status, available_at);
@@ -0,0 +1,16 @@
+CREATE TABLE outbox (
+  id VARCHAR(32) NOT NULL,
+  topic VARCHAR(128) NOT NULL,
+  payload TEXT NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  attempts INTEGER NOT NULL,
+  last_error TEXT NOT NULL,
+  available_at TIMESTAMP NOT NULL,
+  delivered_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (id)
+);
+
+--bun:split
+
+CREATE INDEX outbox_status_available_at_idx ON outbox (status, available_at);
@@ -0,0 +1,101 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+var (
+	ErrMessageNotFound = errors.New("outbox message not found")
+)
+
+// Publish writes a message to the outbox using the database connection in
+// the context. When the context carries a transaction, see
+// database.TxMiddleware, the message is only delivered if it commits.
+func Publish(ctx context.Context, topic string, payload any) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(payload)
+
+	if err != nil {
+		return err
+	}
+
+	msg := Message{
+		ID:      gonanoid.Must(32),
+		Topic:   topic,
+		Payload: string(encoded),
+		Status:  StatusPending,
+	}
+
+	_, err = db.NewInsert().Model(&msg).Exec(ctx)
+
+	return err
+}
+
+// Redrive moves a dead message back to pending with its attempts reset.
+func Redrive(ctx context.Context, id string) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := db.NewUpdate().
+		Model((*Message)(nil)).
+		Where("id = ?", id).
+		Where("status = ?", StatusDead).
+		Set("status = ?", StatusPending).
+		Set("attempts = 0").
+		Set("available_at = ?", time.Now()).
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrMessageNotFound
+	}
+
+	return nil
+}
+
+// PurgeDelivered deletes messages delivered before t.
+func PurgeDelivered(ctx context.Context, t time.Time) (int64, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := db.NewDelete().
+		Model((*Message)(nil)).
+		Where("status = ?", StatusDelivered).
+		Where("delivered_at < ?", t).
+		Exec(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
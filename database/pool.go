@@ -0,0 +1,95 @@
+package database
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/extra/bundebug"
+	"github.com/uptrace/bun/schema"
+)
+
+// pool lazily opens the connection pool of a manager, shared by every call
+// to Db and Bun until it is closed.
+type pool struct {
+	mu sync.Mutex
+	db *bun.DB
+}
+
+func (p *pool) bun(cfg *Config, dialect schema.Dialect, open func() (*sql.DB, error)) (*bun.DB, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.db != nil {
+		return p.db, nil
+	}
+
+	sqldb, err := open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	configurePool(sqldb, cfg)
+
+	bunDb := bun.NewDB(sqldb, dialect)
+
+	if cfg.Debug {
+		bunDb.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(true)))
+	}
+
+	if cfg.LogQueries || cfg.SlowQueryThreshold > 0 {
+		bunDb.AddQueryHook(NewQueryLogHook(cfg))
+	}
+
+	p.db = bunDb
+
+	return bunDb, nil
+}
+
+func (p *pool) sql(cfg *Config, dialect schema.Dialect, open func() (*sql.DB, error)) (*sql.DB, error) {
+
+	bunDb, err := p.bun(cfg, dialect, open)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return bunDb.DB, nil
+}
+
+// close closes the pool, the next call to Db or Bun opens a new one.
+func (p *pool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.db == nil {
+		return nil
+	}
+
+	err := p.db.Close()
+	p.db = nil
+
+	return err
+}
+
+// configurePool applies the pool settings of cfg, zero values keep the
+// database/sql defaults.
+func configurePool(db *sql.DB, cfg *Config) {
+
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+
+	if cfg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+// maintenanceDatabase is connected to when creating or dropping the
+// configured database.
+const maintenanceDatabase = "postgres"
+
+var _ SqlDatabaseManager = (*PostgresManager)(nil)
+
+type PostgresManager struct {
+	cfg  *Config
+	pool pool
+}
+
+func NewPostgresManager(cfg *Config) (*PostgresManager, error) {
+	return &PostgresManager{
+		cfg: cfg,
+	}, nil
+}
+
+func (m *PostgresManager) Create() error {
+	db, err := m.open(false)
+
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	u, err := m.URL()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("CREATE DATABASE " + strings.TrimPrefix(u.Path, "/"))
+
+	return err
+}
+
+func (m *PostgresManager) Drop() error {
+	db, err := m.open(false)
+
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	u, err := m.URL()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DROP DATABASE " + strings.TrimPrefix(u.Path, "/"))
+
+	return err
+}
+
+func (m *PostgresManager) Db() (*sql.DB, error) {
+	return m.pool.sql(m.cfg, pgdialect.New(), m.openPool)
+}
+
+func (m *PostgresManager) Bun() (*bun.DB, error) {
+	return m.pool.bun(m.cfg, pgdialect.New(), m.openPool)
+}
+
+func (m *PostgresManager) Close() error {
+	return m.pool.close()
+}
+
+func (m *PostgresManager) openPool() (*sql.DB, error) {
+	return m.open(true)
+}
+
+// URL returns the connection URL built from Config.URL or the structured
+// fields, with Config.Params applied.
+func (m *PostgresManager) URL() (*url.URL, error) {
+
+	var u *url.URL
+
+	if m.cfg.URL != "" {
+		parsed, err := url.Parse(m.cfg.URL)
+
+		if err != nil {
+			return nil, err
+		}
+
+		u = parsed
+	} else {
+		query := url.Values{}
+		query.Set("sslmode", m.cfg.SSLMode)
+
+		if m.cfg.SearchPath != "" {
+			query.Set("search_path", m.cfg.SearchPath)
+		}
+
+		u = &url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(m.cfg.User, m.cfg.Pass),
+			Host:     net.JoinHostPort(m.cfg.Host, m.cfg.port(defaultPostgresPort)),
+			Path:     "/" + m.cfg.Name,
+			RawQuery: query.Encode(),
+		}
+	}
+
+	if len(m.cfg.Params) > 0 {
+		query := u.Query()
+
+		for k, v := range m.cfg.Params {
+			query.Set(k, v)
+		}
+
+		u.RawQuery = query.Encode()
+	}
+
+	return u, nil
+}
+
+// Connector returns the pgdriver connector of the configured database.
+func (m *PostgresManager) Connector() (*pgdriver.Connector, error) {
+	return m.connector(true)
+}
+
+func (m *PostgresManager) open(withDb bool) (*sql.DB, error) {
+
+	connector, err := m.connector(withDb)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sql.OpenDB(connector), nil
+}
+
+func (m *PostgresManager) connector(withDb bool) (*pgdriver.Connector, error) {
+
+	u, err := m.URL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !withDb {
+		u.Path = "/" + maintenanceDatabase
+	}
+
+	opts := []pgdriver.Option{
+		pgdriver.WithDSN(u.String()),
+	}
+
+	if m.cfg.Socket != "" {
+		opts = append(opts, pgdriver.WithNetwork("unix"), pgdriver.WithAddr(m.cfg.Socket))
+	}
+
+	tlsCfg, err := TLSConfig(m.cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsCfg != nil {
+		// crypto/tls cannot verify the certificate without a server name
+		if tlsCfg.ServerName == "" {
+			tlsCfg.ServerName = u.Hostname()
+		}
+
+		opts = append(opts, pgdriver.WithTLSConfig(tlsCfg))
+	}
+
+	return newPgConnector(opts...)
+}
+
+// newPgConnector returns the error pgdriver panics with on an invalid DSN.
+func newPgConnector(opts ...pgdriver.Option) (connector *pgdriver.Connector, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid postgres dsn: %v", r)
+		}
+	}()
+
+	return pgdriver.NewConnector(opts...), nil
+}
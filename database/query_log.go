@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// sqlValues matches the literals of a query, along with the quoted
+// identifiers and placeholders so that their content is left alone.
+var sqlValues = regexp.MustCompile("'(?:[^']|'')*'|\"(?:[^\"]|\"\")*\"|`[^`]*`|\\$\\d+|\\b\\d+(?:\\.\\d+)?\\b")
+
+// QueryLogHook is a query hook logging queries with slog: failed queries
+// at error, constraint violations at info, queries slower than
+// Config.SlowQueryThreshold at warn and, with Config.LogQueries, a sample
+// of the other ones at info. Every record carries the operation, duration,
+// rows affected and the request ID of the context, see WithRequestID.
+// Literal values are redacted from the query and error unless
+// Config.LogQueryArgs is set.
+type QueryLogHook struct {
+	logger *slog.Logger
+	all    bool
+	slow   time.Duration
+	sample float64
+	args   bool
+}
+
+var _ bun.QueryHook = (*QueryLogHook)(nil)
+
+type QueryLogOption func(h *QueryLogHook)
+
+// WithQueryLogger logs to logger instead of slog.Default.
+func WithQueryLogger(logger *slog.Logger) QueryLogOption {
+	return func(h *QueryLogHook) {
+		h.logger = logger
+	}
+}
+
+// NewQueryLogHook returns a hook logging queries as configured by cfg.
+func NewQueryLogHook(cfg *Config, opts ...QueryLogOption) *QueryLogHook {
+
+	h := &QueryLogHook{
+		all:    cfg.LogQueries,
+		slow:   cfg.SlowQueryThreshold,
+		sample: cfg.LogSampleRate,
+		args:   cfg.LogQueryArgs,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+func (h *QueryLogHook) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *QueryLogHook) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+
+	duration := time.Since(event.StartTime)
+
+	level := slog.LevelInfo
+	msg := "query"
+
+	switch {
+	case isConstraintViolation(event.Err):
+		msg = "query failed"
+	case event.Err != nil && !errors.Is(event.Err, sql.ErrNoRows):
+		level, msg = slog.LevelError, "query failed"
+	case h.slow > 0 && duration >= h.slow:
+		level, msg = slog.LevelWarn, "slow query"
+	case !h.all || !h.sampled():
+		return
+	}
+
+	logger := h.logger
+
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	query := event.Query
+
+	if !h.args {
+		query = RedactQuery(query)
+	}
+
+	attrs := []slog.Attr{
+		slog.String("operation", event.Operation()),
+		slog.String("query", query),
+		slog.Duration("duration", duration),
+	}
+
+	if event.Result != nil {
+		if rows, err := event.Result.RowsAffected(); err == nil {
+			attrs = append(attrs, slog.Int64("rows", rows))
+		}
+	}
+
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		attrs = append(attrs, slog.String("request_id", requestID))
+	}
+
+	if msg == "query failed" {
+		// Errors may quote the values of the query, like a duplicate key
+		text := event.Err.Error()
+
+		if !h.args {
+			text = RedactQuery(text)
+		}
+
+		attrs = append(attrs, slog.String("error", text))
+	}
+
+	logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// sampled reports whether a query is part of the logged sample. A zero
+// rate is taken as unset and logs every query.
+func (h *QueryLogHook) sampled() bool {
+	return h.sample <= 0 || h.sample >= 1 || rand.Float64() < h.sample
+}
+
+// isConstraintViolation reports whether err is a query rejected by a
+// constraint, usually an expected outcome handled by the caller.
+func isConstraintViolation(err error) bool {
+
+	if err == nil {
+		return false
+	}
+
+	err = ClassifyError(err)
+
+	return errors.Is(err, ErrUniqueViolation) ||
+		errors.Is(err, ErrForeignKeyViolation) ||
+		errors.Is(err, ErrNotNull)
+}
+
+// RedactQuery replaces the string and number literals of query with ?,
+// leaving quoted identifiers and placeholders as they are.
+func RedactQuery(query string) string {
+	return sqlValues.ReplaceAllStringFunc(query, func(s string) string {
+		if strings.HasPrefix(s, "'") || s[0] >= '0' && s[0] <= '9' {
+			return "?"
+		}
+
+		return s
+	})
+}
@@ -0,0 +1,129 @@
+package database_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+func TestQueryLogHook(t *testing.T) {
+
+	// Each hook gets its own database, bun cannot remove query hooks
+	open := func() (*bun.DB, func() error) {
+		db, purge, err := dbtest.SQLite("mo_query_log")
+		assert.NoError(t, err)
+
+		_, err = db.NewCreateTable().Model((*item)(nil)).Exec(context.Background())
+		assert.NoError(t, err)
+
+		return db, purge
+	}
+
+	db, closeDB := open()
+	defer closeDB()
+
+	var out bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&out, nil))
+
+	// logged runs fn with a hook configured by cfg and returns its output.
+	logged := func(cfg *database.Config, fn func(ctx context.Context)) string {
+		db, closeDB := open()
+		defer closeDB()
+
+		db.AddQueryHook(database.NewQueryLogHook(cfg, database.WithQueryLogger(logger)))
+
+		fn(database.WithRequestID(database.WithContext(context.Background(), db), "request-1"))
+
+		defer out.Reset()
+
+		return out.String()
+	}
+
+	insert := func(ctx context.Context) {
+		db, err := database.FromContext(ctx)
+		assert.NoError(t, err)
+
+		_, err = db.NewInsert().Model(&item{Source: "secret"}).Exec(ctx)
+		assert.NoError(t, err)
+	}
+
+	t.Run("Queries", func(t *testing.T) {
+		output := logged(&database.Config{LogQueries: true, LogSampleRate: 1}, insert)
+
+		assert.Contains(t, output, "level=INFO msg=query operation=INSERT")
+		assert.Contains(t, output, "rows=1")
+		assert.Contains(t, output, "request_id=request-1")
+		assert.NotContains(t, output, "secret", "values should be redacted")
+
+		output = logged(&database.Config{LogQueries: true, LogSampleRate: 1, LogQueryArgs: true}, insert)
+		assert.Contains(t, output, "secret")
+
+		output = logged(&database.Config{LogQueries: true, LogSampleRate: 1e-9}, insert)
+		assert.Empty(t, output, "queries out of the sample should not be logged")
+
+		output = logged(&database.Config{LogQueries: true}, insert)
+		assert.Contains(t, output, "msg=query", "a zero sample rate should log every query")
+	})
+
+	t.Run("Slow", func(t *testing.T) {
+		output := logged(&database.Config{SlowQueryThreshold: time.Nanosecond}, insert)
+		assert.Contains(t, output, "level=WARN msg=\"slow query\"")
+
+		output = logged(&database.Config{SlowQueryThreshold: time.Hour}, insert)
+		assert.Empty(t, output)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		output := logged(&database.Config{SlowQueryThreshold: time.Hour}, func(ctx context.Context) {
+			_, err := db.NewRaw("SELECT * FROM missing").Exec(ctx)
+			assert.Error(t, err)
+		})
+		assert.Empty(t, output, "queries on another database should not be logged")
+
+		output = logged(&database.Config{SlowQueryThreshold: time.Hour}, func(ctx context.Context) {
+			db, err := database.FromContext(ctx)
+			assert.NoError(t, err)
+
+			_, err = db.NewRaw("SELECT * FROM missing").Exec(ctx)
+			assert.Error(t, err)
+		})
+		assert.Contains(t, output, "level=ERROR msg=\"query failed\"")
+		assert.Contains(t, output, "no such table")
+
+		duplicate := func(ctx context.Context) {
+			db, err := database.FromContext(ctx)
+			assert.NoError(t, err)
+
+			_, err = db.NewInsert().Model(&item{ID: 1, Source: "secret"}).Exec(ctx)
+			assert.NoError(t, err)
+
+			_, err = db.NewRaw("INSERT INTO items (id, source) VALUES (1, 'secret')").Exec(ctx)
+			assert.ErrorIs(t, database.ClassifyError(err), database.ErrUniqueViolation)
+		}
+
+		output = logged(&database.Config{SlowQueryThreshold: time.Hour}, duplicate)
+		assert.Contains(t, output, "level=INFO msg=\"query failed\"", "constraint violations should not be logged as errors")
+		assert.NotContains(t, output, "level=ERROR")
+		assert.NotContains(t, output, "secret", "values should be redacted from the query and error")
+	})
+}
+
+func TestRedactQuery(t *testing.T) {
+
+	assert.Equal(t,
+		`INSERT INTO "items" ("id", "source2") VALUES (?, ?) LIMIT ?`,
+		database.RedactQuery(`INSERT INTO "items" ("id", "source2") VALUES (12, 'it''s 3') LIMIT 1.5`),
+	)
+	assert.Equal(t,
+		"SELECT `t1`.`id` FROM `t1` WHERE `name` = ? AND id = $1",
+		database.RedactQuery("SELECT `t1`.`id` FROM `t1` WHERE `name` = 'x' AND id = $1"),
+	)
+}
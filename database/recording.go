@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/mysqldialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/schema"
+)
+
+var sqlComments = regexp.MustCompile(`(?s)/\*.*?\*/|--[^\n]*`)
+
+// recorder is a query hook collecting the writes issued through a recording
+// database.
+type recorder struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+var _ bun.QueryHook = (*recorder)(nil)
+
+// newRecordingDB returns a database sending reads to db and recording every
+// other query without executing it. Transactions begun on it do nothing.
+func newRecordingDB(db *bun.DB) (*bun.DB, *recorder) {
+
+	rec := &recorder{}
+
+	recording := bun.NewDB(sql.OpenDB(&forwardConnector{target: db.DB, record: true}), newDialect(db.Dialect()))
+	recording.AddQueryHook(rec)
+
+	return recording, rec
+}
+
+// newDialect returns a new dialect named like d, so that a forwarding
+// database does not initialize the dialect of the one it forwards to.
+func newDialect(d schema.Dialect) schema.Dialect {
+	switch d.Name() {
+	case dialect.MySQL:
+		return mysqldialect.New()
+	case dialect.PG:
+		return pgdialect.New()
+	case dialect.SQLite:
+		return sqlitedialect.New()
+	default:
+		return d
+	}
+}
+
+func (r *recorder) BeforeQuery(ctx context.Context, event *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (r *recorder) AfterQuery(ctx context.Context, event *bun.QueryEvent) {
+	switch event.Query {
+	case "BEGIN", "COMMIT", "ROLLBACK":
+		return
+	}
+
+	if isReadQuery(event.Query) {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.queries = append(r.queries, strings.TrimSpace(event.Query))
+}
+
+// take returns the queries recorded since the last call.
+func (r *recorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	queries := r.queries
+	r.queries = nil
+
+	return queries
+}
+
+// isReadQuery reports whether query only reads, so that a recording
+// database can run it.
+func isReadQuery(query string) bool {
+
+	query = strings.ToUpper(strings.TrimSpace(sqlComments.ReplaceAllString(query, "")))
+
+	switch {
+	case strings.HasPrefix(query, "SELECT"), strings.HasPrefix(query, "SHOW"), strings.HasPrefix(query, "EXPLAIN"):
+		return true
+	case strings.HasPrefix(query, "PRAGMA"):
+		return !strings.Contains(query, "=")
+	default:
+		return false
+	}
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	defaultRetryAttempts  = 3
+	defaultRetryBaseDelay = 20 * time.Millisecond
+	defaultRetryMaxDelay  = time.Second
+)
+
+var retryStats struct {
+	retries   atomic.Uint64
+	recovered atomic.Uint64
+	exhausted atomic.Uint64
+}
+
+// RetryStats counts the transactions retried by RunInTxWithRetry and
+// TxMiddleware since the process started.
+type RetryStats struct {
+	// Retries is the number of times a transaction ran again.
+	Retries uint64
+	// Recovered is the number of transactions committed after a retry.
+	Recovered uint64
+	// Exhausted is the number of transactions still failing with a
+	// retryable error after their last attempt.
+	Exhausted uint64
+}
+
+// TxRetryStats returns the retry counters, for metrics.
+func TxRetryStats() RetryStats {
+	return RetryStats{
+		Retries:   retryStats.retries.Load(),
+		Recovered: retryStats.recovered.Load(),
+		Exhausted: retryStats.exhausted.Load(),
+	}
+}
+
+type RetryOption func(o *retryOptions)
+
+type retryOptions struct {
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+}
+
+// WithMaxAttempts runs a transaction at most n times, 3 by default.
+func WithMaxAttempts(n int) RetryOption {
+	return func(o *retryOptions) {
+		o.attempts = n
+	}
+}
+
+// WithRetryBackoff waits between attempts as Backoff does, from base up to
+// max, 20ms and 1s by default.
+func WithRetryBackoff(base time.Duration, max time.Duration) RetryOption {
+	return func(o *retryOptions) {
+		o.baseDelay = base
+		o.maxDelay = max
+	}
+}
+
+func newRetryOptions(opts []RetryOption) *retryOptions {
+
+	o := &retryOptions{
+		attempts:  defaultRetryAttempts,
+		baseDelay: defaultRetryBaseDelay,
+		maxDelay:  defaultRetryMaxDelay,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// IsRetryable reports whether err aborted a transaction that may succeed
+// if it runs again from the start: a deadlock or lock wait timeout on
+// MySQL, a serialization failure, deadlock or lock timeout on Postgres,
+// and a busy or locked database on SQLite.
+func IsRetryable(err error) bool {
+	return errors.Is(ClassifyError(err), ErrDeadlock)
+}
+
+// RunInTxWithRetry runs fn with RunInTx and runs it again, after a
+// jittered backoff, while the transaction fails with an error for which
+// IsRetryable is true. fn must be safe to run several times. Within an
+// enclosing transaction fn runs once, since only the outermost transaction
+// can be retried.
+func RunInTxWithRetry(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error, retryOpts ...RetryOption) error {
+
+	if inTx(ctx) {
+		return RunInTx(ctx, opts, fn)
+	}
+
+	return retry(ctx, newRetryOptions(retryOpts), func() (bool, error) {
+		err := RunInTx(ctx, opts, fn)
+		return IsRetryable(err), err
+	})
+}
+
+// inTx reports whether the database of ctx is a transaction.
+func inTx(ctx context.Context) bool {
+
+	db, err := FromContext(ctx)
+
+	if err != nil {
+		return false
+	}
+
+	_, ok := db.(bun.Tx)
+
+	return ok
+}
+
+// retry calls attempt until it succeeds, returns an error that may not be
+// retried or runs out of attempts.
+func retry(ctx context.Context, o *retryOptions, attempt func() (retryable bool, err error)) error {
+
+	for n := 1; ; n++ {
+		retryable, err := attempt()
+
+		if err == nil {
+			if n > 1 {
+				retryStats.recovered.Add(1)
+			}
+
+			return nil
+		}
+
+		if !retryable {
+			return err
+		}
+
+		if n >= o.attempts {
+			retryStats.exhausted.Add(1)
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(Backoff(n, o.baseDelay, o.maxDelay)):
+		}
+
+		retryStats.retries.Add(1)
+	}
+}
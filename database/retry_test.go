@@ -0,0 +1,183 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+
+	assert.True(t, database.IsRetryable(deadlock))
+	assert.True(t, database.IsRetryable(fmt.Errorf("insert: %w", deadlock)))
+	assert.True(t, database.IsRetryable(&mysql.MySQLError{Number: 1205}))
+	assert.False(t, database.IsRetryable(&mysql.MySQLError{Number: 1062}))
+	assert.False(t, database.IsRetryable(errors.New("boom")))
+	assert.False(t, database.IsRetryable(nil))
+}
+
+func TestRunInTxWithRetry(t *testing.T) {
+
+	db, purge, err := dbtest.SQLite("mo_retry")
+	assert.NoError(t, err)
+
+	defer purge()
+
+	ctx := database.WithContext(context.Background(), db)
+
+	_, err = db.NewCreateTable().Model((*item)(nil)).Exec(ctx)
+	assert.NoError(t, err)
+
+	count := func() int {
+		n, err := db.NewSelect().Model((*item)(nil)).Count(context.Background())
+		assert.NoError(t, err)
+
+		_, err = db.NewDelete().Model((*item)(nil)).Where("1 = 1").Exec(context.Background())
+		assert.NoError(t, err)
+
+		return n
+	}
+
+	// failing inserts an item and fails with a deadlock the first n times.
+	failing := func(n int, attempts *int) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			*attempts++
+
+			tx, err := database.FromContext(ctx)
+			assert.NoError(t, err)
+
+			_, err = tx.NewInsert().Model(&item{Source: "retry"}).Exec(ctx)
+			assert.NoError(t, err)
+
+			if *attempts <= n {
+				return &mysql.MySQLError{Number: 1213}
+			}
+
+			return nil
+		}
+	}
+
+	backoff := database.WithRetryBackoff(time.Millisecond, time.Millisecond)
+
+	t.Run("Recovered", func(t *testing.T) {
+		before := database.TxRetryStats()
+
+		var attempts int
+
+		err := database.RunInTxWithRetry(ctx, nil, failing(2, &attempts), backoff)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, 1, count(), "failed attempts should be rolled back")
+
+		after := database.TxRetryStats()
+		assert.Equal(t, before.Retries+2, after.Retries)
+		assert.Equal(t, before.Recovered+1, after.Recovered)
+		assert.Equal(t, before.Exhausted, after.Exhausted)
+	})
+
+	t.Run("Exhausted", func(t *testing.T) {
+		before := database.TxRetryStats()
+
+		var attempts int
+
+		err := database.RunInTxWithRetry(ctx, nil, failing(5, &attempts), backoff, database.WithMaxAttempts(2))
+		assert.True(t, database.IsRetryable(err))
+		assert.Equal(t, 2, attempts)
+		assert.Equal(t, 0, count())
+
+		after := database.TxRetryStats()
+		assert.Equal(t, before.Retries+1, after.Retries)
+		assert.Equal(t, before.Exhausted+1, after.Exhausted)
+	})
+
+	t.Run("NotRetryable", func(t *testing.T) {
+		boom := errors.New("boom")
+
+		var attempts int
+
+		err := database.RunInTxWithRetry(ctx, nil, func(ctx context.Context) error {
+			attempts++
+			return boom
+		}, backoff)
+		assert.ErrorIs(t, err, boom)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		var attempts int
+
+		err := database.RunInTx(ctx, nil, func(ctx context.Context) error {
+			err := database.RunInTxWithRetry(ctx, nil, failing(1, &attempts), backoff)
+			assert.True(t, database.IsRetryable(err))
+
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, attempts, "only the outermost transaction should be retried")
+		assert.Equal(t, 0, count())
+	})
+
+	t.Run("Middleware", func(t *testing.T) {
+		e := echo.New()
+		e.Use(database.GlobalMiddleware(db))
+		e.Use(database.TxMiddleware(database.WithRetry(backoff)))
+
+		var bodies []string
+
+		e.POST("/", func(c echo.Context) error {
+			body, err := io.ReadAll(c.Request().Body)
+			assert.NoError(t, err)
+
+			bodies = append(bodies, string(body))
+
+			tx, err := database.FromContext(c.Request().Context())
+			assert.NoError(t, err)
+
+			_, err = tx.NewInsert().Model(&item{Source: string(body)}).Exec(c.Request().Context())
+			assert.NoError(t, err)
+
+			if len(bodies) < 3 {
+				return &mysql.MySQLError{Number: 1213}
+			}
+
+			return c.NoContent(http.StatusCreated)
+		})
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload")))
+
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, []string{"payload", "payload", "payload"}, bodies)
+		assert.Equal(t, 1, count())
+
+		// Bodies are only buffered up to the limit
+		e = echo.New()
+		e.Use(database.GlobalMiddleware(db))
+		e.Use(database.TxMiddleware(database.WithRetry(backoff), database.WithRetryBodyLimit(4)))
+		e.POST("/", func(c echo.Context) error {
+			return c.NoContent(http.StatusCreated)
+		})
+
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("payload")))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("data")))
+		assert.Equal(t, http.StatusCreated, rec.Code)
+	})
+}
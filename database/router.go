@@ -0,0 +1,383 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+const defaultHealthInterval = 5 * time.Second
+
+var _ bun.IDB = (*Router)(nil)
+
+type PrimaryKey struct{}
+
+// WithPrimary returns a new context in which FromContext resolves a Router
+// to its primary, so that reads see the writes made before them.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, PrimaryKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(PrimaryKey{}).(bool)
+	return primary
+}
+
+type replica struct {
+	db      *bun.DB
+	healthy atomic.Bool
+}
+
+type replicaSet struct {
+	primary  *bun.DB
+	replicas []*replica
+	next     atomic.Uint64
+	interval time.Duration
+	closers  []func() error
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Router is a bun.IDB sending selects to healthy replicas in turn and every
+// other query, transaction and raw query to the primary. Replicas are
+// health checked in the background between Start and Stop.
+//
+// A Router returned by Session is pinned to the primary as soon as it
+// builds a write query or begins a transaction, see RouterMiddleware.
+type Router struct {
+	set    *replicaSet
+	pinned *atomic.Bool
+}
+
+type RouterOption func(r *Router)
+
+// WithHealthInterval checks the health of replicas every d.
+func WithHealthInterval(d time.Duration) RouterOption {
+	return func(r *Router) {
+		r.set.interval = d
+	}
+}
+
+func NewRouter(primary *bun.DB, replicas []*bun.DB, opts ...RouterOption) *Router {
+	set := &replicaSet{
+		primary:  primary,
+		interval: defaultHealthInterval,
+	}
+
+	for _, db := range replicas {
+		rep := &replica{db: db}
+		rep.healthy.Store(true)
+
+		set.replicas = append(set.replicas, rep)
+	}
+
+	r := &Router{
+		set: set,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// OpenRouter opens the replicas listed in Config.Replicas and returns a
+// Router in front of them and primary. The replicas are closed by Close.
+func OpenRouter(cfg *Config, primary *bun.DB) (*Router, error) {
+
+	var replicas []*bun.DB
+	var closers []func() error
+
+	closeAll := func() {
+		for _, close := range closers {
+			close()
+		}
+	}
+
+	for _, entry := range cfg.Replicas {
+		replicaCfg, err := replicaConfig(cfg, entry)
+
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		manager, err := NewManager(replicaCfg)
+
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		db, err := manager.Bun()
+
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		replicas = append(replicas, db)
+		closers = append(closers, manager.Close)
+	}
+
+	r := NewRouter(primary, replicas, WithHealthInterval(cfg.ReplicaHealthInterval))
+	r.set.closers = closers
+
+	return r, nil
+}
+
+// replicaConfig returns the configuration of a replica entry, either a full
+// URL or DSN, or a host[:port] replacing Config.Host and Config.Port.
+func replicaConfig(cfg *Config, entry string) (*Config, error) {
+
+	replicaCfg := *cfg
+	replicaCfg.Replicas = nil
+
+	if strings.Contains(entry, "://") || strings.Contains(entry, "@") {
+		replicaCfg.URL = entry
+		return &replicaCfg, nil
+	}
+
+	if cfg.URL != "" {
+		return nil, fmt.Errorf("replica %s: a full URL is required when URL is set", entry)
+	}
+
+	host, port, err := net.SplitHostPort(entry)
+
+	if err != nil {
+		host, port = entry, cfg.Port
+	}
+
+	replicaCfg.Host = host
+	replicaCfg.Port = port
+
+	return &replicaCfg, nil
+}
+
+// Primary returns the primary database.
+func (r *Router) Primary() *bun.DB {
+	return r.set.primary
+}
+
+// Session returns a Router sharing the replicas of r that pins itself to
+// the primary after a write. Use one session per request.
+func (r *Router) Session() *Router {
+	return &Router{
+		set:    r.set,
+		pinned: new(atomic.Bool),
+	}
+}
+
+// Start checks the health of the replicas in the background until Stop is
+// called.
+func (r *Router) Start() {
+	if len(r.set.replicas) == 0 || r.set.interval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	r.set.cancel = cancel
+	r.set.done = make(chan struct{})
+
+	go r.set.check(ctx)
+}
+
+// Stop stops the health checks.
+func (r *Router) Stop(ctx context.Context) error {
+	if r.set.cancel == nil {
+		return nil
+	}
+
+	r.set.cancel()
+
+	select {
+	case <-r.set.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close closes the replicas opened by OpenRouter.
+func (r *Router) Close() error {
+
+	var errs []error
+
+	for _, close := range r.set.closers {
+		errs = append(errs, close())
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *replicaSet) check(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for i, rep := range s.replicas {
+			pingCtx, cancel := context.WithTimeout(ctx, s.interval)
+			err := rep.db.PingContext(pingCtx)
+			cancel()
+
+			healthy := err == nil
+
+			if rep.healthy.Swap(healthy) != healthy {
+				if healthy {
+					slog.Info("database replica recovered", "replica", i)
+				} else {
+					slog.Warn("database replica unhealthy", "replica", i, "error", err)
+				}
+			}
+		}
+	}
+}
+
+// reader returns the next healthy replica, or the primary when there is
+// none or the router is pinned.
+func (r *Router) reader() *bun.DB {
+
+	if r.pinned != nil && r.pinned.Load() {
+		return r.set.primary
+	}
+
+	n := uint64(len(r.set.replicas))
+
+	if n == 0 {
+		return r.set.primary
+	}
+
+	start := r.set.next.Add(1)
+
+	for i := uint64(0); i < n; i++ {
+		rep := r.set.replicas[(start+i)%n]
+
+		if rep.healthy.Load() {
+			return rep.db
+		}
+	}
+
+	return r.set.primary
+}
+
+// writer pins the router and returns the primary.
+func (r *Router) writer() *bun.DB {
+
+	if r.pinned != nil {
+		r.pinned.Store(true)
+	}
+
+	return r.set.primary
+}
+
+func (r *Router) readerContext(ctx context.Context) *bun.DB {
+
+	if usePrimary(ctx) {
+		return r.set.primary
+	}
+
+	return r.reader()
+}
+
+// QueryContext runs a raw query on a replica unless ctx comes from
+// WithPrimary. Raw writes must use ExecContext.
+func (r *Router) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.readerContext(ctx).QueryContext(ctx, query, args...)
+}
+
+func (r *Router) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return r.readerContext(ctx).QueryRowContext(ctx, query, args...)
+}
+
+func (r *Router) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.writer().ExecContext(ctx, query, args...)
+}
+
+func (r *Router) Dialect() schema.Dialect {
+	return r.set.primary.Dialect()
+}
+
+func (r *Router) NewValues(model interface{}) *bun.ValuesQuery {
+	return r.set.primary.NewValues(model)
+}
+
+func (r *Router) NewSelect() *bun.SelectQuery {
+	return r.reader().NewSelect()
+}
+
+func (r *Router) NewInsert() *bun.InsertQuery {
+	return r.writer().NewInsert()
+}
+
+func (r *Router) NewUpdate() *bun.UpdateQuery {
+	return r.writer().NewUpdate()
+}
+
+func (r *Router) NewDelete() *bun.DeleteQuery {
+	return r.writer().NewDelete()
+}
+
+func (r *Router) NewMerge() *bun.MergeQuery {
+	return r.writer().NewMerge()
+}
+
+// NewRaw runs on the primary since the query may write.
+func (r *Router) NewRaw(query string, args ...interface{}) *bun.RawQuery {
+	return r.writer().NewRaw(query, args...)
+}
+
+func (r *Router) NewCreateTable() *bun.CreateTableQuery {
+	return r.writer().NewCreateTable()
+}
+
+func (r *Router) NewDropTable() *bun.DropTableQuery {
+	return r.writer().NewDropTable()
+}
+
+func (r *Router) NewCreateIndex() *bun.CreateIndexQuery {
+	return r.writer().NewCreateIndex()
+}
+
+func (r *Router) NewDropIndex() *bun.DropIndexQuery {
+	return r.writer().NewDropIndex()
+}
+
+func (r *Router) NewTruncateTable() *bun.TruncateTableQuery {
+	return r.writer().NewTruncateTable()
+}
+
+func (r *Router) NewAddColumn() *bun.AddColumnQuery {
+	return r.writer().NewAddColumn()
+}
+
+func (r *Router) NewDropColumn() *bun.DropColumnQuery {
+	return r.writer().NewDropColumn()
+}
+
+func (r *Router) BeginTx(ctx context.Context, opts *sql.TxOptions) (bun.Tx, error) {
+	return r.writer().BeginTx(ctx, opts)
+}
+
+func (r *Router) RunInTx(ctx context.Context, opts *sql.TxOptions, f func(ctx context.Context, tx bun.Tx) error) error {
+	return r.writer().RunInTx(ctx, opts, f)
+}
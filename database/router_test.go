@@ -0,0 +1,106 @@
+package database_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+type item struct {
+	bun.BaseModel `bun:"items"`
+	ID            int64  `bun:"id,pk,autoincrement"`
+	Source        string `bun:"source"`
+}
+
+func TestRouter(t *testing.T) {
+
+	dir := t.TempDir()
+
+	open := func(name string) (*bun.DB, database.SqlDatabaseManager) {
+		manager, err := database.NewSQLiteManager(&database.Config{
+			Dialect: "sqlite",
+			Name:    filepath.Join(dir, name+".db"),
+		})
+		assert.NoError(t, err)
+
+		db, err := manager.Bun()
+		assert.NoError(t, err)
+
+		_, err = db.NewCreateTable().Model((*item)(nil)).Exec(context.Background())
+		assert.NoError(t, err)
+
+		_, err = db.NewInsert().Model(&item{Source: name}).Exec(context.Background())
+		assert.NoError(t, err)
+
+		return db, manager
+	}
+
+	primary, primaryManager := open("primary")
+	replica, replicaManager := open("replica")
+
+	defer primaryManager.Close()
+
+	router := database.NewRouter(primary, []*bun.DB{replica}, database.WithHealthInterval(20*time.Millisecond))
+
+	source := func(ctx context.Context) string {
+		db, err := database.FromContext(ctx)
+		assert.NoError(t, err)
+
+		var it item
+
+		err = db.NewSelect().Model(&it).Order("id").Limit(1).Scan(ctx)
+		assert.NoError(t, err)
+
+		return it.Source
+	}
+
+	t.Run("Session", func(t *testing.T) {
+		session := router.Session()
+		ctx := database.WithContext(context.Background(), session)
+
+		assert.Equal(t, "replica", source(ctx), "reads should go to the replica")
+		assert.Equal(t, "primary", source(database.WithPrimary(ctx)), "WithPrimary should read from the primary")
+
+		_, err := session.NewInsert().Model(&item{Source: "write"}).Exec(ctx)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "primary", source(ctx), "reads after a write should go to the primary")
+
+		// Other sessions are not pinned
+		assert.Equal(t, "replica", source(database.WithContext(context.Background(), router.Session())))
+	})
+
+	t.Run("Transaction", func(t *testing.T) {
+		session := router.Session()
+
+		tx, err := session.BeginTx(context.Background(), nil)
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Rollback())
+
+		assert.Equal(t, "primary", source(database.WithContext(context.Background(), session)))
+	})
+
+	t.Run("Health", func(t *testing.T) {
+		router.Start()
+		defer router.Stop(context.Background())
+
+		ctx := database.WithContext(context.Background(), router)
+
+		assert.Equal(t, "replica", source(ctx))
+
+		assert.NoError(t, replicaManager.Close())
+
+		assert.Eventually(t, func() bool {
+			var it item
+
+			err := router.NewSelect().Model(&it).Order("id").Limit(1).Scan(ctx)
+
+			return err == nil && it.Source == "primary"
+		}, time.Second, 20*time.Millisecond, "reads should fall back to the primary")
+	})
+}
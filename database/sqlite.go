@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteMemory is the database name of an in-memory SQLite database.
+const SQLiteMemory = ":memory:"
+
+var _ SqlDatabaseManager = (*SQLiteManager)(nil)
+
+// SQLiteManager manages a SQLite database stored in the file at
+// Config.Name, or at the path of Config.URL, or in memory when it is
+// SQLiteMemory. An in-memory database lives as long as the connection pool
+// of the manager, which is then limited to a single connection.
+type SQLiteManager struct {
+	cfg  *Config
+	pool pool
+}
+
+func NewSQLiteManager(cfg *Config) (*SQLiteManager, error) {
+	m := &SQLiteManager{
+		cfg: cfg,
+	}
+
+	// Every connection to :memory: opens a different database
+	if m.memory() {
+		memCfg := *cfg
+		memCfg.MaxOpenConns = 1
+		memCfg.MaxIdleConns = 1
+		memCfg.ConnMaxLifetime = 0
+		memCfg.ConnMaxIdleTime = 0
+
+		m.cfg = &memCfg
+	}
+
+	return m, nil
+}
+
+func (m *SQLiteManager) Create() error {
+
+	if m.memory() {
+		return nil
+	}
+
+	f, err := os.OpenFile(m.path(), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+func (m *SQLiteManager) Drop() error {
+
+	if m.memory() {
+		return nil
+	}
+
+	var errs []error
+
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Remove(m.path() + suffix); err != nil && (suffix == "" || !errors.Is(err, fs.ErrNotExist)) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (m *SQLiteManager) Db() (*sql.DB, error) {
+	return m.pool.sql(m.cfg, sqlitedialect.New(), m.open)
+}
+
+func (m *SQLiteManager) Bun() (*bun.DB, error) {
+	return m.pool.bun(m.cfg, sqlitedialect.New(), m.open)
+}
+
+func (m *SQLiteManager) Close() error {
+	return m.pool.close()
+}
+
+func (m *SQLiteManager) open() (*sql.DB, error) {
+
+	query := url.Values{}
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", "busy_timeout(5000)")
+	// Take the write lock when a transaction begins, upgrading a read lock
+	// later fails without waiting for busy_timeout
+	query.Set("_txlock", "immediate")
+
+	if !m.memory() {
+		query.Add("_pragma", "journal_mode(WAL)")
+	}
+
+	if _, rawQuery, ok := strings.Cut(m.cfg.URL, "?"); ok {
+		params, err := url.ParseQuery(rawQuery)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for k, values := range params {
+			for _, v := range values {
+				setSQLiteParam(query, k, v)
+			}
+		}
+	}
+
+	for k, v := range m.cfg.Params {
+		setSQLiteParam(query, k, v)
+	}
+
+	return sql.Open("sqlite", "file:"+m.path()+"?"+query.Encode())
+}
+
+// path returns the path of the database file.
+func (m *SQLiteManager) path() string {
+
+	if m.cfg.URL == "" {
+		return m.cfg.Name
+	}
+
+	path, _, _ := strings.Cut(strings.TrimPrefix(m.cfg.URL, "file:"), "?")
+
+	return path
+}
+
+func (m *SQLiteManager) memory() bool {
+	return m.path() == SQLiteMemory
+}
+
+// setSQLiteParam sets a connection parameter, _pragma may be given several
+// times.
+func setSQLiteParam(query url.Values, k string, v string) {
+	if k == "_pragma" {
+		query.Add(k, v)
+	} else {
+		query.Set(k, v)
+	}
+}
@@ -0,0 +1,47 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joelywz/mo/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLitePool(t *testing.T) {
+
+	manager, err := database.NewSQLiteManager(&database.Config{
+		Dialect:      "sqlite",
+		Name:         database.SQLiteMemory,
+		MaxOpenConns: 10,
+	})
+	assert.NoError(t, err)
+
+	db, err := manager.Bun()
+	assert.NoError(t, err)
+
+	again, err := manager.Bun()
+	assert.NoError(t, err)
+	assert.Same(t, db, again, "bun should reuse the pool")
+
+	sqldb, err := manager.Db()
+	assert.NoError(t, err)
+	assert.Same(t, db.DB, sqldb, "db should reuse the pool")
+	assert.Equal(t, 1, sqldb.Stats().MaxOpenConnections, "memory database should use a single connection")
+
+	// The in-memory database is shared through the pool
+	_, err = db.ExecContext(context.Background(), "CREATE TABLE items (id INTEGER PRIMARY KEY)")
+	assert.NoError(t, err)
+
+	_, err = sqldb.Exec("INSERT INTO items (id) VALUES (1)")
+	assert.NoError(t, err)
+
+	assert.NoError(t, manager.Close())
+	assert.Error(t, db.Ping(), "closed pool should not be usable")
+
+	reopened, err := manager.Bun()
+	assert.NoError(t, err)
+	assert.NotSame(t, db, reopened)
+	assert.NoError(t, reopened.Ping())
+	assert.NoError(t, manager.Close())
+}
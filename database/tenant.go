@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/uptrace/bun"
+)
+
+type TenantKey struct{}
+
+var (
+	ErrNoTenantInContext = errors.New("no tenant in context")
+)
+
+// WithTenant returns a new context scoped to a tenant.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, TenantKey{}, tenantID)
+}
+
+// TenantFromContext retrieves the tenant the context is scoped to.
+// Returns ErrNoTenantInContext if the context is not scoped to a tenant.
+func TenantFromContext(ctx context.Context) (string, error) {
+	tenantID, ok := ctx.Value(TenantKey{}).(string)
+
+	if !ok || tenantID == "" {
+		return "", ErrNoTenantInContext
+	}
+	return tenantID, nil
+}
+
+// ScopeTenant restricts select, update and delete queries to rows whose
+// column matches the tenant the context is scoped to. Queries fail with
+// ErrNoTenantInContext rather than leak rows across tenants when the
+// context is not scoped.
+//
+//	db.NewSelect().Model(&orders).ApplyQueryBuilder(database.ScopeTenant(ctx, "organization_id"))
+func ScopeTenant(ctx context.Context, column string) func(bun.QueryBuilder) bun.QueryBuilder {
+	return func(q bun.QueryBuilder) bun.QueryBuilder {
+		tenantID, err := TenantFromContext(ctx)
+
+		if err != nil {
+			switch q := q.Unwrap().(type) {
+			case *bun.SelectQuery:
+				q.Err(err)
+			case *bun.UpdateQuery:
+				q.Err(err)
+			case *bun.DeleteQuery:
+				q.Err(err)
+			}
+
+			return q
+		}
+
+		return q.Where("? = ?", bun.Ident(column), tenantID)
+	}
+}
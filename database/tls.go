@@ -0,0 +1,61 @@
+package database
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	ErrInvalidCA = errors.New("no certificates found in CA file")
+)
+
+// TLSConfig returns the TLS configuration described by cfg, or nil when TLS
+// is not enabled. The server name defaults to Config.Host unless a URL is
+// given, the managers then take it from the URL.
+func TLSConfig(cfg *Config) (*tls.Config, error) {
+
+	if !cfg.TLS && cfg.TLSCA == "" && cfg.TLSCert == "" && cfg.TLSKey == "" {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		ServerName:         cfg.TLSServerName,
+		InsecureSkipVerify: cfg.TLSSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if tlsCfg.ServerName == "" && cfg.URL == "" {
+		tlsCfg.ServerName = cfg.Host
+	}
+
+	if cfg.TLSCA != "" {
+		pem, err := os.ReadFile(cfg.TLSCA)
+
+		if err != nil {
+			return nil, fmt.Errorf("reading CA: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, ErrInvalidCA
+		}
+
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.TLSCert != "" || cfg.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
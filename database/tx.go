@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/uptrace/bun"
+)
+
+// RunInTx runs fn with a context holding a transaction begun on the
+// database of ctx. When ctx already holds a transaction, fn runs in a
+// savepoint of it instead and opts is ignored.
+//
+// The transaction, or savepoint, rolls back if fn returns an error or
+// panics, the panic propagating to the caller, and commits otherwise.
+// Callbacks registered with AfterCommit run once the outermost transaction
+// commits and are dropped if the savepoint they were registered in rolls
+// back.
+func RunInTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+
+	db, err := FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	var tx bun.Tx
+
+	if parent, ok := db.(bun.Tx); ok {
+		tx, err = parent.BeginTx(ctx, nil)
+	} else {
+		tx, err = db.BeginTx(ctx, opts)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	txCtx, queue := withAfterCommitQueue(ctx)
+	txCtx = WithContext(txCtx, tx)
+
+	done := false
+
+	defer func() {
+		if !done {
+			tx.Rollback()
+		}
+	}()
+
+	if err := fn(txCtx); err != nil {
+		done = true
+
+		if txErr := tx.Rollback(); txErr != nil {
+			err = errors.Join(err, txErr)
+		}
+
+		return err
+	}
+
+	done = true
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	queue.commit(context.WithoutCancel(ctx))
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+)
+
+func TestRunInTx(t *testing.T) {
+
+	db, purge, err := dbtest.SQLite("mo_tx")
+	assert.NoError(t, err)
+
+	defer purge()
+
+	ctx := database.WithContext(context.Background(), db)
+
+	_, err = db.NewCreateTable().Model((*item)(nil)).Exec(ctx)
+	assert.NoError(t, err)
+
+	insert := func(ctx context.Context, source string) {
+		tx, err := database.FromContext(ctx)
+		assert.NoError(t, err)
+
+		_, err = tx.NewInsert().Model(&item{Source: source}).Exec(ctx)
+		assert.NoError(t, err)
+	}
+
+	sources := func() []string {
+		var items []item
+
+		assert.NoError(t, db.NewSelect().Model(&items).Order("id").Scan(context.Background()))
+
+		var sources []string
+
+		for _, it := range items {
+			sources = append(sources, it.Source)
+		}
+
+		db.NewDelete().Model((*item)(nil)).Where("1 = 1").Exec(context.Background())
+
+		return sources
+	}
+
+	boom := errors.New("boom")
+
+	t.Run("Savepoint", func(t *testing.T) {
+		var committed []string
+
+		err := database.RunInTx(ctx, nil, func(ctx context.Context) error {
+			insert(ctx, "outer")
+			database.AfterCommit(ctx, func(context.Context) { committed = append(committed, "outer") })
+
+			err := database.RunInTx(ctx, nil, func(ctx context.Context) error {
+				insert(ctx, "rolled back")
+				database.AfterCommit(ctx, func(context.Context) { committed = append(committed, "rolled back") })
+
+				return boom
+			})
+			assert.ErrorIs(t, err, boom)
+
+			return database.RunInTx(ctx, nil, func(ctx context.Context) error {
+				insert(ctx, "inner")
+				database.AfterCommit(ctx, func(context.Context) { committed = append(committed, "inner") })
+
+				assert.Empty(t, committed, "callbacks should wait for the outermost commit")
+
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{"outer", "inner"}, sources())
+		assert.Equal(t, []string{"outer", "inner"}, committed)
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		err := database.RunInTx(ctx, nil, func(ctx context.Context) error {
+			insert(ctx, "outer")
+
+			return database.RunInTx(ctx, nil, func(ctx context.Context) error {
+				insert(ctx, "inner")
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.Len(t, sources(), 2)
+
+		err = database.RunInTx(ctx, nil, func(ctx context.Context) error {
+			insert(ctx, "outer")
+			return boom
+		})
+		assert.ErrorIs(t, err, boom)
+		assert.Empty(t, sources())
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		assert.PanicsWithValue(t, "boom", func() {
+			database.RunInTx(ctx, nil, func(ctx context.Context) error {
+				insert(ctx, "panic")
+				panic("boom")
+			})
+		})
+
+		assert.Empty(t, sources())
+
+		// The connection is usable again
+		assert.NoError(t, database.RunInTx(ctx, nil, func(ctx context.Context) error { return nil }))
+	})
+
+	t.Run("Middleware", func(t *testing.T) {
+		e := echo.New()
+		e.Use(database.GlobalMiddleware(db), database.TxMiddleware())
+
+		e.POST("/ok", func(c echo.Context) error {
+			tx, err := database.FromContext(c.Request().Context())
+			assert.NoError(t, err)
+			assert.IsType(t, bun.Tx{}, tx)
+
+			insert(c.Request().Context(), "ok")
+
+			return c.NoContent(http.StatusNoContent)
+		})
+
+		e.POST("/error", func(c echo.Context) error {
+			insert(c.Request().Context(), "error")
+			return boom
+		})
+
+		for _, path := range []string{"/ok", "/error"} {
+			e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, nil))
+		}
+
+		assert.Equal(t, []string{"ok"}, sources())
+	})
+}
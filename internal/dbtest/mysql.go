@@ -0,0 +1,80 @@
+// Package dbtest provides disposable databases for tests.
+package dbtest
+
+import (
+	"fmt"
+
+	"github.com/joelywz/mo/database"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/uptrace/bun"
+)
+
+// MySQL starts a MySQL container with a database called name and returns a
+// connection to it, along with a function purging the container.
+func MySQL(name string) (*bun.DB, func() error, error) {
+	pool, err := dockertest.NewPool("")
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not construct pool: %w", err)
+	}
+
+	err = pool.Client.Ping()
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not connect to docker: %w", err)
+	}
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "mysql",
+		Tag:        "latest",
+		Env: []string{
+			"MYSQL_ROOT_PASSWORD=root",
+			"MYSQL_DATABASE=" + name,
+		},
+	}, func(config *docker.HostConfig) {
+		config.AutoRemove = true
+		config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+	})
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not start resource: %w", err)
+	}
+
+	purge := func() error {
+		return pool.Purge(resource)
+	}
+
+	if err := resource.Expire(60); err != nil {
+		purge()
+		return nil, nil, fmt.Errorf("could not set expiry for resource: %w", err)
+	}
+
+	manager, err := database.NewMySQLManager(&database.Config{
+		Dialect: "mysql",
+		Host:    "localhost",
+		Port:    resource.GetPort("3306/tcp"),
+		User:    "root",
+		Pass:    "root",
+		Name:    name,
+	})
+
+	if err != nil {
+		purge()
+		return nil, nil, err
+	}
+
+	db, err := manager.Bun()
+
+	if err != nil {
+		purge()
+		return nil, nil, err
+	}
+
+	if err := pool.Retry(db.Ping); err != nil {
+		purge()
+		return nil, nil, fmt.Errorf("could not connect to mysql: %w", err)
+	}
+
+	return db, purge, nil
+}
@@ -0,0 +1,83 @@
+package dbtest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/joelywz/mo/database"
+	"github.com/uptrace/bun"
+)
+
+// SQLite creates a SQLite database called name in a temporary directory
+// and returns a connection to it, along with a function removing it.
+func SQLite(name string) (*bun.DB, func() error, error) {
+	manager, purge, err := SQLiteManager(name)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := manager.Bun()
+
+	if err != nil {
+		purge()
+		return nil, nil, err
+	}
+
+	return db, purge, nil
+}
+
+// SQLiteManager is SQLite for tests needing the manager, to run migrations
+// with database.MigrateUp for instance.
+func SQLiteManager(name string) (*database.SQLiteManager, func() error, error) {
+	dir, err := os.MkdirTemp("", "dbtest")
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	removeDir := func() error {
+		return os.RemoveAll(dir)
+	}
+
+	manager, err := database.NewSQLiteManager(&database.Config{
+		Dialect: "sqlite",
+		Name:    filepath.Join(dir, name+".db"),
+	})
+
+	if err != nil {
+		removeDir()
+		return nil, nil, err
+	}
+
+	purge := func() error {
+		return errors.Join(manager.Close(), removeDir())
+	}
+
+	db, err := manager.Bun()
+
+	if err != nil {
+		purge()
+		return nil, nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		purge()
+		return nil, nil, fmt.Errorf("could not open sqlite: %w", err)
+	}
+
+	return manager, purge, nil
+}
+
+// Open returns a database called name using the dialect in DB_DIALECT,
+// SQLite when it is "sqlite" and a MySQL container otherwise. SQLite does
+// not need Docker and is much faster to start.
+func Open(name string) (*bun.DB, func() error, error) {
+	if os.Getenv("DB_DIALECT") == "sqlite" {
+		return SQLite(name)
+	}
+
+	return MySQL(name)
+}
@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/caarlos0/env/v11"
+)
+
+type Config struct {
+	// Queues maps each queue to work on to its concurrency limit.
+	Queues       map[string]int `env:"JOBS_QUEUES" envKeyValSeparator:":" envDefault:"default:10"`
+	PollInterval time.Duration  `env:"JOBS_POLL_INTERVAL" envDefault:"1s"`
+	MaxAttempts  int            `env:"JOBS_MAX_ATTEMPTS" envDefault:"25"`
+	BaseBackoff  time.Duration  `env:"JOBS_BASE_BACKOFF" envDefault:"1s"`
+	MaxBackoff   time.Duration  `env:"JOBS_MAX_BACKOFF" envDefault:"1h"`
+	// RescueAfter returns running jobs to the queue when their runner has
+	// not refreshed their lock in time, for instance because it crashed.
+	// Runners refresh the locks of their jobs every RescueAfter / 4.
+	RescueAfter time.Duration `env:"JOBS_RESCUE_AFTER" envDefault:"1h"`
+}
+
+func ParseConfig() (*Config, error) {
+	cfg, err := env.ParseAs[Config]()
+	return &cfg, err
+}
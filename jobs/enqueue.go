@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+const DefaultQueue = "default"
+
+var (
+	ErrDuplicateJob = errors.New("duplicate job")
+)
+
+type enqueueConfig struct {
+	queue       string
+	runAt       time.Time
+	uniqueKey   *string
+	maxAttempts int
+}
+
+type EnqueueOption func(cfg *enqueueConfig)
+
+// InQueue places the job in queue instead of DefaultQueue.
+func InQueue(queue string) EnqueueOption {
+	return func(cfg *enqueueConfig) {
+		cfg.queue = queue
+	}
+}
+
+// RunAt schedules the job to run no earlier than t.
+func RunAt(t time.Time) EnqueueOption {
+	return func(cfg *enqueueConfig) {
+		cfg.runAt = t
+	}
+}
+
+// RunIn delays the job by d.
+func RunIn(d time.Duration) EnqueueOption {
+	return func(cfg *enqueueConfig) {
+		cfg.runAt = time.Now().Add(d)
+	}
+}
+
+// Unique rejects the job with ErrDuplicateJob while another job with the
+// same key is pending or running.
+func Unique(key string) EnqueueOption {
+	return func(cfg *enqueueConfig) {
+		cfg.uniqueKey = &key
+	}
+}
+
+// MaxAttempts overrides the maximum attempts of the runner for this job.
+func MaxAttempts(n int) EnqueueOption {
+	return func(cfg *enqueueConfig) {
+		cfg.maxAttempts = n
+	}
+}
+
+// Enqueue adds a job of kind with JSON encoded args using the database
+// connection in the context. When the context carries a transaction, see
+// database.TxMiddleware, the job only becomes visible once it commits.
+func Enqueue(ctx context.Context, kind string, args any, opts ...EnqueueOption) (*Job, error) {
+
+	cfg := enqueueConfig{
+		queue: DefaultQueue,
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	payload, err := json.Marshal(args)
+
+	if err != nil {
+		return nil, err
+	}
+
+	job := Job{
+		ID:          gonanoid.Must(32),
+		Queue:       cfg.queue,
+		Kind:        kind,
+		Payload:     string(payload),
+		Status:      StatusPending,
+		MaxAttempts: cfg.maxAttempts,
+		UniqueKey:   cfg.uniqueKey,
+		RunAt:       cfg.runAt,
+	}
+
+	// Within a transaction a rejected insert only rolls back its savepoint,
+	// the caller's transaction stays usable on postgres
+	err = database.RunInTx(ctx, nil, func(ctx context.Context) error {
+
+		db, err := database.FromContext(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		if cfg.uniqueKey != nil {
+			exists, err := db.NewSelect().
+				Model((*Job)(nil)).
+				Where("unique_key = ?", *cfg.uniqueKey).
+				Exists(ctx)
+
+			if err != nil {
+				return err
+			}
+
+			if exists {
+				return ErrDuplicateJob
+			}
+		}
+
+		_, err = db.NewInsert().Model(&job).Exec(ctx)
+
+		// A concurrent enqueue won the race for the unique key
+		if cfg.uniqueKey != nil && errors.Is(database.ClassifyError(err), database.ErrUniqueViolation) {
+			return ErrDuplicateJob
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// Kind is a job kind whose arguments are of type T.
+type Kind[T any] struct {
+	Name string
+}
+
+func NewKind[T any](name string) Kind[T] {
+	return Kind[T]{
+		Name: name,
+	}
+}
+
+// Enqueue adds a job of this kind, see Enqueue.
+func (k Kind[T]) Enqueue(ctx context.Context, args T, opts ...EnqueueOption) (*Job, error) {
+	return Enqueue(ctx, k.Name, args, opts...)
+}
@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+var (
+	_ bun.BeforeAppendModelHook = (*Job)(nil)
+	_ database.IndexedModel     = (*Job)(nil)
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "PENDING"
+	StatusRunning   Status = "RUNNING"
+	StatusSucceeded Status = "SUCCEEDED"
+	// StatusFailed marks jobs that exhausted their attempts.
+	StatusFailed Status = "FAILED"
+)
+
+type Job struct {
+	bun.BaseModel `bun:"jobs"`
+	ID            string     `bun:"id,pk,notnull,type:varchar(32)"`
+	Queue         string     `bun:"queue,notnull,type:varchar(64)"`
+	Kind          string     `bun:"kind,notnull,type:varchar(128)"`
+	Payload       string     `bun:"payload,notnull,type:text"`
+	Status        Status     `bun:"status,notnull,type:varchar(16)"`
+	Attempts      int        `bun:"attempts,notnull"`
+	MaxAttempts   int        `bun:"max_attempts,notnull"`
+	UniqueKey     *string    `bun:"unique_key,unique,type:varchar(255)"`
+	LastError     string     `bun:"last_error,notnull,type:text"`
+	RunAt         time.Time  `bun:"run_at,notnull"`
+	LockedAt      *time.Time `bun:"locked_at"`
+	FinishedAt    *time.Time `bun:"finished_at"`
+	CreatedAt     time.Time  `bun:"created_at,notnull"`
+	UpdatedAt     time.Time  `bun:"updated_at,notnull"`
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (j *Job) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		j.CreatedAt = time.Now()
+		j.UpdatedAt = time.Now()
+
+		if j.RunAt.IsZero() {
+			j.RunAt = j.CreatedAt
+		}
+	case *bun.UpdateQuery:
+		j.UpdatedAt = time.Now()
+	}
+
+	return nil
+}
+
+// Indexes implements database.IndexedModel.
+func (j *Job) Indexes() []database.Index {
+	return []database.Index{
+		{Name: "jobs_queue_status_run_at_idx", Columns: []string{"queue", "status", "run_at"}},
+	}
+}
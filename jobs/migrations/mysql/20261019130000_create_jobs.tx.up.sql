@@ -0,0 +1,19 @@
+CREATE TABLE jobs (
+  id VARCHAR(32) NOT NULL,
+  queue VARCHAR(64) NOT NULL,
+  kind VARCHAR(128) NOT NULL,
+  payload TEXT NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  attempts BIGINT NOT NULL,
+  max_attempts BIGINT NOT NULL,
+  unique_key VARCHAR(255),
+  last_error TEXT NOT NULL,
+  run_at DATETIME NOT NULL,
+  locked_at DATETIME,
+  finished_at DATETIME,
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL,
+  PRIMARY KEY (id),
+  UNIQUE INDEX jobs_unique_key_idx (unique_key),
+  INDEX jobs_queue_status_run_at_idx (queue, status, run_at)
+);
@@ -0,0 +1,25 @@
+CREATE TABLE jobs (
+  id VARCHAR(32) NOT NULL,
+  queue VARCHAR(64) NOT NULL,
+  kind VARCHAR(128) NOT NULL,
+  payload TEXT NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  attempts BIGINT NOT NULL,
+  max_attempts BIGINT NOT NULL,
+  unique_key VARCHAR(255),
+  last_error TEXT NOT NULL,
+  run_at TIMESTAMPTZ NOT NULL,
+  locked_at TIMESTAMPTZ,
+  finished_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (id)
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX jobs_unique_key_idx ON jobs (unique_key);
+
+--bun:split
+
+CREATE INDEX jobs_queue_status_run_at_idx ON jobs (queue, status, run_at);
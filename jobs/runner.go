@@ -0,0 +1,374 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/uptrace/bun"
+	"go.uber.org/fx"
+)
+
+const rescueInterval = time.Minute
+
+var (
+	ErrNoHandler = errors.New("no job handler for kind")
+)
+
+type handlerFunc func(ctx context.Context, job *Job) error
+
+// Runner claims jobs from the queues listed in Config.Queues and runs them
+// with the handler registered for their kind. Jobs are claimed with SELECT
+// ... FOR UPDATE SKIP LOCKED (see database.SkipLocked), so several runners
+// can share the queues.
+type Runner struct {
+	db  *bun.DB
+	cfg *Config
+
+	mu       sync.RWMutex
+	handlers map[string]handlerFunc
+
+	cancel     context.CancelFunc
+	cancelWork context.CancelFunc
+	pollers    sync.WaitGroup
+	workers    sync.WaitGroup
+}
+
+func NewRunner(db *bun.DB, cfg *Config) *Runner {
+	return &Runner{
+		db:       db,
+		cfg:      cfg,
+		handlers: map[string]handlerFunc{},
+	}
+}
+
+// Handle registers the handler for jobs of kind k, replacing any previous
+// one. Returning an error retries the job with backoff until it runs out of
+// attempts.
+func Handle[T any](r *Runner, k Kind[T], fn func(ctx context.Context, args T) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.handlers[k.Name] = func(ctx context.Context, job *Job) error {
+		var args T
+
+		if err := json.Unmarshal([]byte(job.Payload), &args); err != nil {
+			return err
+		}
+
+		return fn(ctx, args)
+	}
+}
+
+// Start polls the queues in the background until Stop is called.
+func (r *Runner) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	workCtx, cancelWork := context.WithCancel(context.Background())
+
+	r.cancel = cancel
+	r.cancelWork = cancelWork
+
+	for queue, concurrency := range r.cfg.Queues {
+		r.pollers.Add(1)
+
+		go r.poll(ctx, workCtx, queue, concurrency)
+	}
+}
+
+// Stop stops claiming jobs and waits for running jobs to finish. If ctx is
+// done first, running jobs are cancelled.
+func (r *Runner) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+
+	r.cancel()
+	r.pollers.Wait()
+
+	done := make(chan struct{})
+
+	go func() {
+		r.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		r.cancelWork()
+		return nil
+	case <-ctx.Done():
+		r.cancelWork()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) poll(ctx context.Context, workCtx context.Context, queue string, concurrency int) {
+	defer r.pollers.Done()
+
+	slots := make(chan struct{}, concurrency)
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	var rescued time.Time
+
+	for {
+		if time.Since(rescued) >= rescueInterval {
+			if err := r.rescue(ctx, queue); err != nil && ctx.Err() == nil {
+				slog.Error("rescuing jobs", "queue", queue, "error", err)
+			}
+
+			rescued = time.Now()
+		}
+
+		free := concurrency - len(slots)
+
+		if free > 0 {
+			jobs, err := r.claim(ctx, queue, free)
+
+			if err != nil && ctx.Err() == nil {
+				slog.Error("claiming jobs", "queue", queue, "error", err)
+			}
+
+			for i := range jobs {
+				job := &jobs[i]
+
+				slots <- struct{}{}
+				r.workers.Add(1)
+
+				go func() {
+					defer func() {
+						<-slots
+						r.workers.Done()
+					}()
+
+					r.run(workCtx, job)
+				}()
+			}
+
+			// Claim again right away while there is more work than slots
+			if err == nil && len(jobs) == free && len(slots) < concurrency {
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim locks up to limit due jobs of queue and marks them as running.
+func (r *Runner) claim(ctx context.Context, queue string, limit int) ([]Job, error) {
+
+	var jobs []Job
+
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+
+		err := tx.NewSelect().
+			Model(&jobs).
+			Where("queue = ?", queue).
+			Where("status = ?", StatusPending).
+			Where("run_at <= ?", time.Now()).
+			Order("run_at", "id").
+			Limit(limit).
+			Apply(database.SkipLocked).
+			Scan(ctx)
+
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+
+		ids := make([]string, 0, len(jobs))
+		now := time.Now()
+
+		for i := range jobs {
+			ids = append(ids, jobs[i].ID)
+
+			jobs[i].Status = StatusRunning
+			jobs[i].Attempts++
+			jobs[i].LockedAt = &now
+		}
+
+		_, err = tx.NewUpdate().
+			Model((*Job)(nil)).
+			Where("id IN (?)", bun.In(ids)).
+			Set("status = ?", StatusRunning).
+			Set("attempts = attempts + 1").
+			Set("locked_at = ?", now).
+			Set("updated_at = ?", now).
+			Exec(ctx)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (r *Runner) run(ctx context.Context, job *Job) {
+
+	r.mu.RLock()
+	handler, ok := r.handlers[job.Kind]
+	r.mu.RUnlock()
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	heartbeatDone := make(chan struct{})
+
+	go func() {
+		defer close(heartbeatDone)
+		r.heartbeat(heartbeatCtx, job)
+	}()
+
+	var err error
+
+	if ok {
+		err = r.safeHandle(database.WithContext(ctx, r.db), handler, job)
+	} else {
+		err = fmt.Errorf("%w: %s", ErrNoHandler, job.Kind)
+	}
+
+	stopHeartbeat()
+	<-heartbeatDone
+
+	now := time.Now()
+
+	job.LockedAt = nil
+
+	maxAttempts := job.MaxAttempts
+
+	if maxAttempts <= 0 {
+		maxAttempts = r.cfg.MaxAttempts
+	}
+
+	switch {
+	case err == nil:
+		job.Status = StatusSucceeded
+		job.FinishedAt = &now
+		job.UniqueKey = nil
+	case job.Attempts >= maxAttempts:
+		job.Status = StatusFailed
+		job.FinishedAt = &now
+		job.UniqueKey = nil
+		job.LastError = err.Error()
+
+		slog.Warn("job failed", "id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "error", err)
+	default:
+		job.Status = StatusPending
+		job.RunAt = now.Add(database.Backoff(job.Attempts, r.cfg.BaseBackoff, r.cfg.MaxBackoff))
+		job.LastError = err.Error()
+	}
+
+	// Record the outcome even when the runner is shutting down, unless the
+	// job was rescued in the meantime
+	res, err := r.db.NewUpdate().
+		Model(job).
+		Column("status", "run_at", "last_error", "unique_key", "locked_at", "finished_at", "updated_at").
+		WherePK().
+		Apply(owned(job)).
+		Exec(context.WithoutCancel(ctx))
+
+	if err != nil {
+		slog.Error("updating job", "id", job.ID, "error", err)
+		return
+	}
+
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		slog.Warn("job rescued before it finished", "id", job.ID, "kind", job.Kind)
+	}
+}
+
+// heartbeat refreshes the lock of job until ctx is done, so that rescue
+// leaves it alone however long it runs.
+func (r *Runner) heartbeat(ctx context.Context, job *Job) {
+
+	interval := r.cfg.RescueAfter / 4
+
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_, err := r.db.NewUpdate().
+			Model((*Job)(nil)).
+			Where("id = ?", job.ID).
+			Apply(owned(job)).
+			Set("locked_at = ?", time.Now()).
+			Exec(ctx)
+
+		if err != nil && ctx.Err() == nil {
+			slog.Error("refreshing job lock", "id", job.ID, "error", err)
+		}
+	}
+}
+
+// owned restricts an update to job as long as it is still running the
+// attempt claimed by this runner. Rescued jobs went back to pending, and
+// claiming them again counted another attempt.
+func owned(job *Job) func(q *bun.UpdateQuery) *bun.UpdateQuery {
+	return func(q *bun.UpdateQuery) *bun.UpdateQuery {
+		return q.
+			Where("status = ?", StatusRunning).
+			Where("attempts = ?", job.Attempts)
+	}
+}
+
+func (r *Runner) safeHandle(ctx context.Context, handler handlerFunc, job *Job) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("job handler panic: %v", rec)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+// rescue returns running jobs of queue whose lock was not refreshed within
+// Config.RescueAfter, because their runner stopped, to pending.
+func (r *Runner) rescue(ctx context.Context, queue string) error {
+
+	_, err := r.db.NewUpdate().
+		Model((*Job)(nil)).
+		Where("queue = ?", queue).
+		Where("status = ?", StatusRunning).
+		Where("locked_at < ?", time.Now().Add(-r.cfg.RescueAfter)).
+		Set("status = ?", StatusPending).
+		Set("locked_at = NULL").
+		Set("updated_at = ?", time.Now()).
+		Exec(ctx)
+
+	return err
+}
+
+// Run starts the runner with the fx lifecycle.
+func Run(lc fx.Lifecycle, r *Runner) {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			r.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return r.Stop(ctx)
+		},
+	})
+}
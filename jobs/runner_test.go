@@ -0,0 +1,308 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/joelywz/mo/jobs"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+var db *bun.DB
+
+func TestMain(m *testing.M) {
+	var (
+		purge func() error
+		err   error
+	)
+
+	db, purge, err = dbtest.Open("mo_jobs")
+
+	if err != nil {
+		log.Fatalf("Could not start database: %s", err)
+	}
+
+	// Migrate database
+	migrations := migrate.NewMigrations()
+	jobs.RegisterMigrations(migrations)
+
+	migrator := migrate.NewMigrator(db, migrations)
+
+	if err := migrator.Init(context.Background()); err != nil {
+		log.Fatalf("Could not init migrations: %s", err)
+	}
+
+	if _, err := migrator.Migrate(context.Background()); err != nil {
+		log.Fatalf("Could not migrate: %s", err)
+	}
+
+	code := m.Run()
+
+	if err := purge(); err != nil {
+		log.Fatalf("Could not purge resource: %s", err)
+	}
+
+	os.Exit(code)
+}
+
+func TestMigrations(t *testing.T) {
+
+	drifts, err := database.DetectDrift(context.Background(), db, jobs.Models()...)
+
+	assert.NoError(t, err)
+	assert.Empty(t, drifts, "migrations should match the models")
+}
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+func TestRunner(t *testing.T) {
+
+	runner := jobs.NewRunner(db, &jobs.Config{
+		Queues:       map[string]int{jobs.DefaultQueue: 2},
+		PollInterval: 50 * time.Millisecond,
+		MaxAttempts:  3,
+		BaseBackoff:  10 * time.Millisecond,
+		MaxBackoff:   50 * time.Millisecond,
+		RescueAfter:  time.Hour,
+	})
+
+	greet := jobs.NewKind[greeting]("greet")
+	flaky := jobs.NewKind[greeting]("flaky")
+
+	greeted := make(chan string, 10)
+
+	jobs.Handle(runner, greet, func(ctx context.Context, args greeting) error {
+		greeted <- args.Name
+		return nil
+	})
+
+	var attempts atomic.Int32
+
+	jobs.Handle(runner, flaky, func(ctx context.Context, args greeting) error {
+		attempts.Add(1)
+		return errors.New("always failing")
+	})
+
+	ctx := context.Background()
+	ctx = database.WithContext(ctx, db)
+
+	// Jobs enqueued in a rolled back transaction never run
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := greet.Enqueue(database.WithContext(ctx, tx), greeting{Name: "rolled back"}); err != nil {
+			return err
+		}
+
+		return errors.New("rollback")
+	})
+
+	assert.Error(t, err)
+
+	// Unique jobs
+	_, err = greet.Enqueue(ctx, greeting{Name: "unique"}, jobs.Unique("greet:unique"))
+
+	assert.NoError(t, err, "enqueue should not return error")
+
+	_, err = greet.Enqueue(ctx, greeting{Name: "unique"}, jobs.Unique("greet:unique"))
+
+	assert.ErrorIs(t, err, jobs.ErrDuplicateJob, "enqueue should return ErrDuplicateJob")
+
+	// Delayed jobs
+	_, err = greet.Enqueue(ctx, greeting{Name: "delayed"}, jobs.RunIn(300*time.Millisecond))
+
+	assert.NoError(t, err, "enqueue should not return error")
+
+	failing, err := flaky.Enqueue(ctx, greeting{Name: "flaky"})
+
+	assert.NoError(t, err, "enqueue should not return error")
+
+	runner.Start()
+
+	for _, expected := range []string{"unique", "delayed"} {
+		select {
+		case name := <-greeted:
+			assert.Equal(t, expected, name)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("job %q did not run", expected)
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		var job jobs.Job
+
+		if err := db.NewSelect().Model(&job).Where("id = ?", failing.ID).Scan(ctx); err != nil {
+			return false
+		}
+
+		return job.Status == jobs.StatusFailed
+	}, 5*time.Second, 50*time.Millisecond, "failing job should end up failed")
+
+	assert.Equal(t, int32(3), attempts.Load(), "failing job should be retried up to max attempts")
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, runner.Stop(stopCtx), "stop should not return error")
+
+	select {
+	case name := <-greeted:
+		t.Fatalf("unexpected job %q ran", name)
+	default:
+	}
+
+	// The unique key is released once the job finished
+	_, err = greet.Enqueue(ctx, greeting{Name: "unique"}, jobs.Unique("greet:unique"))
+
+	assert.NoError(t, err, "enqueue should not return error after the unique job finished")
+}
+
+func TestRescue(t *testing.T) {
+
+	runner := jobs.NewRunner(db, &jobs.Config{
+		Queues:       map[string]int{"rescue": 2},
+		PollInterval: 50 * time.Millisecond,
+		MaxAttempts:  3,
+		BaseBackoff:  10 * time.Millisecond,
+		MaxBackoff:   50 * time.Millisecond,
+		RescueAfter:  time.Hour,
+	})
+
+	greet := jobs.NewKind[greeting]("rescued_greet")
+	greeted := make(chan string, 10)
+
+	jobs.Handle(runner, greet, func(ctx context.Context, args greeting) error {
+		greeted <- args.Name
+		return nil
+	})
+
+	ctx := context.Background()
+
+	running := func(name string, lockedAt time.Time) *jobs.Job {
+		job := &jobs.Job{
+			ID:          name,
+			Queue:       "rescue",
+			Kind:        greet.Name,
+			Payload:     `{"name":"` + name + `"}`,
+			Status:      jobs.StatusRunning,
+			Attempts:    1,
+			MaxAttempts: 3,
+			LockedAt:    &lockedAt,
+		}
+
+		_, err := db.NewInsert().Model(job).Exec(ctx)
+		assert.NoError(t, err)
+
+		return job
+	}
+
+	// Only the job whose runner stopped refreshing its lock is rescued
+	running("stale", time.Now().Add(-2*time.Hour))
+	live := running("live", time.Now().Add(-time.Minute))
+
+	runner.Start()
+
+	select {
+	case name := <-greeted:
+		assert.Equal(t, "stale", name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stale job was not rescued")
+	}
+
+	assert.NoError(t, runner.Stop(context.Background()))
+
+	assert.NoError(t, db.NewSelect().Model(live).WherePK().Scan(ctx))
+	assert.Equal(t, jobs.StatusRunning, live.Status, "live job should be left running")
+
+	// A job rescued while it runs is not finished by its former runner
+	reclaimed := jobs.NewKind[greeting]("reclaimed")
+
+	jobs.Handle(runner, reclaimed, func(ctx context.Context, args greeting) error {
+		_, err := db.NewUpdate().
+			Model((*jobs.Job)(nil)).
+			Where("kind = ?", reclaimed.Name).
+			Set("attempts = attempts + 1").
+			Exec(ctx)
+
+		return err
+	})
+
+	job, err := reclaimed.Enqueue(database.WithContext(ctx, db), greeting{}, jobs.InQueue("rescue"))
+	assert.NoError(t, err)
+
+	runner.Start()
+
+	assert.Eventually(t, func() bool {
+		var current jobs.Job
+
+		err := db.NewSelect().Model(&current).Where("id = ?", job.ID).Scan(ctx)
+
+		return err == nil && current.Attempts == 2
+	}, 5*time.Second, 50*time.Millisecond)
+
+	assert.NoError(t, runner.Stop(context.Background()))
+
+	assert.NoError(t, db.NewSelect().Model(job).WherePK().Scan(ctx))
+	assert.Equal(t, jobs.StatusRunning, job.Status, "former runner should not record the outcome")
+}
+
+func TestUnique(t *testing.T) {
+
+	ctx := database.WithContext(context.Background(), db)
+
+	unique := jobs.NewKind[greeting]("unique_race")
+	other := jobs.NewKind[greeting]("unique_race_other")
+
+	// Concurrent transactions enqueue the same unique job, the losers keep
+	// using their transaction afterwards
+	var wg sync.WaitGroup
+
+	errs := make(chan error, 10)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			errs <- database.RunInTx(ctx, nil, func(ctx context.Context) error {
+				_, err := unique.Enqueue(ctx, greeting{}, jobs.InQueue("unique"), jobs.Unique("unique:race"))
+
+				if err != nil && !errors.Is(err, jobs.ErrDuplicateJob) {
+					return err
+				}
+
+				_, err = other.Enqueue(ctx, greeting{}, jobs.InQueue("unique"))
+
+				return err
+			})
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err, "transaction should survive a duplicate job")
+	}
+
+	count := func(kind string) int {
+		n, err := db.NewSelect().Model((*jobs.Job)(nil)).Where("kind = ?", kind).Count(ctx)
+		assert.NoError(t, err)
+
+		return n
+	}
+
+	assert.Equal(t, 1, count(unique.Name))
+	assert.Equal(t, 10, count(other.Name))
+}
@@ -0,0 +1,51 @@
+package rbac
+
+import (
+	"context"
+	"sync"
+)
+
+type CacheKey struct{}
+
+// cache holds the effective permissions resolved during a single request,
+// keyed by auth user and tenant.
+type cache struct {
+	mu          sync.Mutex
+	permissions map[string][]Permission
+}
+
+// WithCache returns a new context in which effective permissions are only
+// resolved once per auth user and tenant. It should be scoped to a single
+// request since changes to roles are not picked up by the cache.
+func WithCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, CacheKey{}, &cache{
+		permissions: map[string][]Permission{},
+	})
+}
+
+func cacheFromContext(ctx context.Context) *cache {
+	c, _ := ctx.Value(CacheKey{}).(*cache)
+	return c
+}
+
+func (c *cache) get(key string) ([]Permission, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	permissions, ok := c.permissions[key]
+	return permissions, ok
+}
+
+func (c *cache) set(key string, permissions []Permission) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.permissions[key] = permissions
+}
+
+func (c *cache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.permissions)
+}
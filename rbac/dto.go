@@ -0,0 +1,20 @@
+package rbac
+
+type CreateRoleRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RoleResponse struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Inherits    []string     `json:"inherits"`
+	Permissions []Permission `json:"permissions"`
+}
+
+type AssignRequest struct {
+	AuthUserID string `json:"authUserId"`
+	RoleID     string `json:"roleId"`
+	TenantID   string `json:"tenantId"`
+}
@@ -0,0 +1,48 @@
+package rbac
+
+import (
+	"github.com/joelywz/mo/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// CacheMiddleware prepares the request context with a permission cache, see
+// WithCache.
+func CacheMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := WithCache(c.Request().Context())
+
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
+
+// Require rejects requests whose authenticated auth user lacks permission
+// with 403 Forbidden. It must run after auth.Middleware.
+func Require(s *Service, permission Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			res, err := auth.FromContext(ctx)
+
+			if err != nil {
+				return echo.ErrUnauthorized.WithInternal(err)
+			}
+
+			ok, err := s.Can(ctx, res.AuthUserID, permission)
+
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				return echo.ErrForbidden
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package rbac
+
+import (
+	"embed"
+	"fmt"
+
+	"github.com/joelywz/mo/database"
+	"github.com/uptrace/bun/migrate"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migrations creates and evolves the tables of the rbac package. The SQL
+// run depends on the dialect of the database being migrated, see
+// database.DialectMigrations.
+var Migrations *migrate.Migrations
+
+func init() {
+
+	var err error
+
+	if Migrations, err = database.DialectMigrations(migrationFiles, "migrations"); err != nil {
+		panic(fmt.Sprintf("rbac: %s", err))
+	}
+}
+
+// RegisterMigrations adds the rbac migrations to m, so that they run
+// alongside the migrations of the app, see auth.RegisterMigrations.
+func RegisterMigrations(m *migrate.Migrations) {
+	for _, migration := range Migrations.Sorted() {
+		m.Add(migration)
+	}
+}
+
+// Models returns the models of the rbac package, to check them against the
+// database with database.DetectDrift.
+func Models() []any {
+	return []any{
+		(*Role)(nil),
+		(*RoleInherit)(nil),
+		(*RolePermission)(nil),
+		(*Assignment)(nil),
+	}
+}
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS rbac_assignments;
+
+--bun:split
+
+DROP TABLE IF EXISTS rbac_role_permissions;
+
+--bun:split
+
+DROP TABLE IF EXISTS rbac_role_inherits;
+
+--bun:split
+
+DROP TABLE IF EXISTS rbac_roles;
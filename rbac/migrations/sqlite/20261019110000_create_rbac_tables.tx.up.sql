@@ -0,0 +1,50 @@
+CREATE TABLE rbac_roles (
+  id VARCHAR(32) NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  description VARCHAR(255) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (id)
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX rbac_roles_name_idx ON rbac_roles (name);
+
+--bun:split
+
+CREATE TABLE rbac_role_inherits (
+  role_id VARCHAR(32) NOT NULL,
+  inherited_role_id VARCHAR(32) NOT NULL,
+  PRIMARY KEY (role_id, inherited_role_id),
+  CONSTRAINT rbac_role_inherits_role_id_fk FOREIGN KEY (role_id) REFERENCES rbac_roles (id) ON DELETE CASCADE,
+  CONSTRAINT rbac_role_inherits_inherited_role_id_fk FOREIGN KEY (inherited_role_id) REFERENCES rbac_roles (id) ON DELETE CASCADE
+);
+
+--bun:split
+
+CREATE INDEX rbac_role_inherits_inherited_role_id_idx ON rbac_role_inherits (inherited_role_id);
+
+--bun:split
+
+CREATE TABLE rbac_role_permissions (
+  role_id VARCHAR(32) NOT NULL,
+  permission VARCHAR(128) NOT NULL,
+  PRIMARY KEY (role_id, permission),
+  CONSTRAINT rbac_role_permissions_role_id_fk FOREIGN KEY (role_id) REFERENCES rbac_roles (id) ON DELETE CASCADE
+);
+
+--bun:split
+
+CREATE TABLE rbac_assignments (
+  auth_user_id VARCHAR(32) NOT NULL,
+  role_id VARCHAR(32) NOT NULL,
+  tenant_id VARCHAR(32) NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (auth_user_id, role_id, tenant_id),
+  CONSTRAINT rbac_assignments_role_id_fk FOREIGN KEY (role_id) REFERENCES rbac_roles (id) ON DELETE CASCADE
+);
+
+--bun:split
+
+CREATE INDEX rbac_assignments_role_id_idx ON rbac_assignments (role_id);
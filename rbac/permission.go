@@ -0,0 +1,67 @@
+package rbac
+
+import (
+	"errors"
+	"strings"
+)
+
+// Wildcard matches any resource or action.
+const Wildcard = "*"
+
+var (
+	ErrInvalidPermission = errors.New("invalid permission")
+)
+
+// Permission is a resource:action pair such as "orders:write". Either part
+// may be the wildcard "*", so "orders:*" grants every action on orders and
+// "*:*" grants everything.
+type Permission string
+
+// NewPermission returns the permission for an action on a resource.
+func NewPermission(resource string, action string) Permission {
+	return Permission(resource + ":" + action)
+}
+
+// ParsePermission validates s and returns it as a Permission.
+func ParsePermission(s string) (Permission, error) {
+	p := Permission(s)
+
+	if !p.Valid() {
+		return "", ErrInvalidPermission
+	}
+
+	return p, nil
+}
+
+// Resource returns the resource part of the permission.
+func (p Permission) Resource() string {
+	resource, _, _ := strings.Cut(string(p), ":")
+	return resource
+}
+
+// Action returns the action part of the permission.
+func (p Permission) Action() string {
+	_, action, _ := strings.Cut(string(p), ":")
+	return action
+}
+
+// Valid reports whether the permission is a non-empty resource:action pair.
+func (p Permission) Valid() bool {
+	resource, action, ok := strings.Cut(string(p), ":")
+
+	return ok && resource != "" && action != "" && !strings.Contains(action, ":")
+}
+
+// Matches reports whether the granted permission p covers target. Wildcards
+// are only honoured on p.
+func (p Permission) Matches(target Permission) bool {
+	if !p.Valid() || !target.Valid() {
+		return false
+	}
+
+	return matchPart(p.Resource(), target.Resource()) && matchPart(p.Action(), target.Action())
+}
+
+func matchPart(granted string, target string) bool {
+	return granted == Wildcard || granted == target
+}
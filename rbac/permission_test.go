@@ -0,0 +1,47 @@
+package rbac_test
+
+import (
+	"testing"
+
+	"github.com/joelywz/mo/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionMatches(t *testing.T) {
+
+	cases := []struct {
+		granted rbac.Permission
+		target  rbac.Permission
+		match   bool
+	}{
+		{"orders:read", "orders:read", true},
+		{"orders:read", "orders:write", false},
+		{"orders:*", "orders:write", true},
+		{"orders:*", "invoices:write", false},
+		{"*:read", "invoices:read", true},
+		{"*:read", "invoices:write", false},
+		{"*:*", "invoices:delete", true},
+		{"orders:read", "orders:*", false},
+		{"orders", "orders:read", false},
+		{"*:*", "orders", false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.match, c.granted.Matches(c.target), "%s matches %s", c.granted, c.target)
+	}
+}
+
+func TestParsePermission(t *testing.T) {
+
+	p, err := rbac.ParsePermission("orders:write")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "orders", p.Resource())
+	assert.Equal(t, "write", p.Action())
+
+	for _, s := range []string{"", "orders", ":write", "orders:", "a:b:c"} {
+		_, err := rbac.ParsePermission(s)
+
+		assert.ErrorIs(t, err, rbac.ErrInvalidPermission, s)
+	}
+}
@@ -0,0 +1,86 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+var (
+	_ bun.BeforeAppendModelHook = (*Role)(nil)
+	_ bun.BeforeAppendModelHook = (*Assignment)(nil)
+	_ database.IndexedModel     = (*RoleInherit)(nil)
+	_ database.IndexedModel     = (*Assignment)(nil)
+)
+
+type Role struct {
+	bun.BaseModel `bun:"rbac_roles"`
+	ID            string    `bun:"id,pk,notnull,type:varchar(32)"`
+	Name          string    `bun:"name,notnull,unique,type:varchar(64)"`
+	Description   string    `bun:"description,notnull,type:varchar(255)"`
+	CreatedAt     time.Time `bun:"created_at,notnull"`
+	UpdatedAt     time.Time `bun:"updated_at,notnull"`
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (r *Role) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		r.CreatedAt = time.Now()
+		r.UpdatedAt = time.Now()
+	case *bun.UpdateQuery:
+		r.UpdatedAt = time.Now()
+	}
+
+	return nil
+}
+
+// RoleInherit makes RoleID inherit every permission of InheritedRoleID.
+type RoleInherit struct {
+	bun.BaseModel   `bun:"rbac_role_inherits"`
+	RoleID          string `bun:"role_id,pk,notnull,type:varchar(32)"`
+	InheritedRoleID string `bun:"inherited_role_id,pk,notnull,type:varchar(32)"`
+}
+
+// Indexes implements database.IndexedModel.
+func (r *RoleInherit) Indexes() []database.Index {
+	return []database.Index{
+		{Name: "rbac_role_inherits_inherited_role_id_idx", Columns: []string{"inherited_role_id"}},
+	}
+}
+
+type RolePermission struct {
+	bun.BaseModel `bun:"rbac_role_permissions"`
+	RoleID        string     `bun:"role_id,pk,notnull,type:varchar(32)"`
+	Permission    Permission `bun:"permission,pk,notnull,type:varchar(128)"`
+}
+
+// Assignment assigns a role to an auth user. An empty TenantID assigns the
+// role globally, across every tenant.
+type Assignment struct {
+	bun.BaseModel `bun:"rbac_assignments"`
+	AuthUserID    string    `bun:"auth_user_id,pk,notnull,type:varchar(32)"`
+	RoleID        string    `bun:"role_id,pk,notnull,type:varchar(32)"`
+	TenantID      string    `bun:"tenant_id,pk,notnull,type:varchar(32)"`
+	CreatedAt     time.Time `bun:"created_at,notnull"`
+}
+
+// Indexes implements database.IndexedModel.
+func (a *Assignment) Indexes() []database.Index {
+	return []database.Index{
+		{Name: "rbac_assignments_role_id_idx", Columns: []string{"role_id"}},
+	}
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (a *Assignment) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		a.CreatedAt = time.Now()
+	}
+
+	return nil
+}
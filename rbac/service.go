@@ -0,0 +1,461 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/joelywz/mo/database"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/uptrace/bun"
+)
+
+var (
+	ErrRoleExists       = errors.New("role already exists")
+	ErrRoleNotFound     = errors.New("role not found")
+	ErrInheritanceCycle = errors.New("role inheritance cycle")
+)
+
+type Service struct{}
+
+func NewService() *Service {
+	return &Service{}
+}
+
+// Can reports whether an auth user has been granted permission, either
+// through a global role assignment or one for the tenant the context is
+// scoped to (see database.WithTenant).
+func (s *Service) Can(ctx context.Context, authUserId string, permission Permission) (bool, error) {
+
+	permissions, err := s.Permissions(ctx, authUserId)
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, granted := range permissions {
+		if granted.Matches(permission) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Permissions returns the effective permissions of an auth user, following
+// role inheritance. Results are cached if the context was prepared with
+// WithCache.
+func (s *Service) Permissions(ctx context.Context, authUserId string) ([]Permission, error) {
+
+	tenantID, _ := database.TenantFromContext(ctx)
+
+	cache := cacheFromContext(ctx)
+	key := authUserId + "\x00" + tenantID
+
+	if cache != nil {
+		if permissions, ok := cache.get(key); ok {
+			return permissions, nil
+		}
+	}
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tenants := []string{""}
+
+	if tenantID != "" {
+		tenants = append(tenants, tenantID)
+	}
+
+	var roleIDs []string
+
+	err = db.NewSelect().
+		Model((*Assignment)(nil)).
+		Column("role_id").
+		Where("auth_user_id = ?", authUserId).
+		Where("tenant_id IN (?)", bun.In(tenants)).
+		Scan(ctx, &roleIDs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	roleIDs, err = s.closure(ctx, db, roleIDs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := []Permission{}
+
+	if len(roleIDs) > 0 {
+		err = db.NewSelect().
+			Model((*RolePermission)(nil)).
+			Column("permission").
+			Distinct().
+			Where("role_id IN (?)", bun.In(roleIDs)).
+			Scan(ctx, &permissions)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cache != nil {
+		cache.set(key, permissions)
+	}
+
+	return permissions, nil
+}
+
+// CreateRole creates a role without any permissions.
+func (s *Service) CreateRole(ctx context.Context, dto *CreateRoleRequest) (*RoleResponse, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	role := Role{
+		ID:          gonanoid.Must(32),
+		Name:        dto.Name,
+		Description: dto.Description,
+	}
+
+	// The unique index on the name rejects a taken one
+	if _, err := db.NewInsert().Model(&role).Exec(ctx); err != nil {
+		if errors.Is(database.ClassifyError(err), database.ErrUniqueViolation) {
+			return nil, ErrRoleExists
+		}
+
+		return nil, err
+	}
+
+	return &RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Inherits:    []string{},
+		Permissions: []Permission{},
+	}, nil
+}
+
+// DeleteRole deletes a role along with its permissions, inheritance and
+// assignments.
+func (s *Service) DeleteRole(ctx context.Context, roleId string) error {
+
+	if err := s.ensureRole(ctx, roleId); err != nil {
+		return err
+	}
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+
+		if _, err := tx.NewDelete().Model((*RolePermission)(nil)).Where("role_id = ?", roleId).Exec(ctx); err != nil {
+			return err
+		}
+
+		if _, err := tx.NewDelete().Model((*RoleInherit)(nil)).Where("role_id = ? OR inherited_role_id = ?", roleId, roleId).Exec(ctx); err != nil {
+			return err
+		}
+
+		if _, err := tx.NewDelete().Model((*Assignment)(nil)).Where("role_id = ?", roleId).Exec(ctx); err != nil {
+			return err
+		}
+
+		if _, err := tx.NewDelete().Model((*Role)(nil)).Where("id = ?", roleId).Exec(ctx); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	return s.invalidate(ctx, err)
+}
+
+// ListRoles returns every role with its direct inheritance and permissions.
+func (s *Service) ListRoles(ctx context.Context) ([]RoleResponse, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []Role
+
+	if err := db.NewSelect().Model(&roles).Order("name").Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	var inherits []RoleInherit
+
+	if err := db.NewSelect().Model(&inherits).Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	var permissions []RolePermission
+
+	if err := db.NewSelect().Model(&permissions).Order("permission").Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	res := make([]RoleResponse, 0, len(roles))
+
+	for _, role := range roles {
+		item := RoleResponse{
+			ID:          role.ID,
+			Name:        role.Name,
+			Description: role.Description,
+			Inherits:    []string{},
+			Permissions: []Permission{},
+		}
+
+		for _, inherit := range inherits {
+			if inherit.RoleID == role.ID {
+				item.Inherits = append(item.Inherits, inherit.InheritedRoleID)
+			}
+		}
+
+		for _, permission := range permissions {
+			if permission.RoleID == role.ID {
+				item.Permissions = append(item.Permissions, permission.Permission)
+			}
+		}
+
+		res = append(res, item)
+	}
+
+	return res, nil
+}
+
+// InheritRole makes roleId inherit every permission of inheritedRoleId.
+// Returns ErrInheritanceCycle if inheritedRoleId already inherits roleId.
+func (s *Service) InheritRole(ctx context.Context, roleId string, inheritedRoleId string) error {
+
+	if err := s.ensureRole(ctx, roleId); err != nil {
+		return err
+	}
+
+	if err := s.ensureRole(ctx, inheritedRoleId); err != nil {
+		return err
+	}
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	closure, err := s.closure(ctx, db, []string{inheritedRoleId})
+
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(closure, roleId) {
+		return ErrInheritanceCycle
+	}
+
+	_, err = db.NewInsert().
+		Model(&RoleInherit{RoleID: roleId, InheritedRoleID: inheritedRoleId}).
+		Ignore().
+		Exec(ctx)
+
+	return s.invalidate(ctx, err)
+}
+
+// DisinheritRole removes an inheritance created by InheritRole.
+func (s *Service) DisinheritRole(ctx context.Context, roleId string, inheritedRoleId string) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewDelete().
+		Model((*RoleInherit)(nil)).
+		Where("role_id = ?", roleId).
+		Where("inherited_role_id = ?", inheritedRoleId).
+		Exec(ctx)
+
+	return s.invalidate(ctx, err)
+}
+
+// GrantPermission grants a permission to a role.
+func (s *Service) GrantPermission(ctx context.Context, roleId string, permission Permission) error {
+
+	if !permission.Valid() {
+		return ErrInvalidPermission
+	}
+
+	if err := s.ensureRole(ctx, roleId); err != nil {
+		return err
+	}
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewInsert().
+		Model(&RolePermission{RoleID: roleId, Permission: permission}).
+		Ignore().
+		Exec(ctx)
+
+	return s.invalidate(ctx, err)
+}
+
+// RevokePermission revokes a permission from a role.
+func (s *Service) RevokePermission(ctx context.Context, roleId string, permission Permission) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewDelete().
+		Model((*RolePermission)(nil)).
+		Where("role_id = ?", roleId).
+		Where("permission = ?", permission).
+		Exec(ctx)
+
+	return s.invalidate(ctx, err)
+}
+
+// Assign assigns a role to an auth user, globally if dto.TenantID is empty.
+func (s *Service) Assign(ctx context.Context, dto *AssignRequest) error {
+
+	if err := s.ensureRole(ctx, dto.RoleID); err != nil {
+		return err
+	}
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewInsert().
+		Model(&Assignment{
+			AuthUserID: dto.AuthUserID,
+			RoleID:     dto.RoleID,
+			TenantID:   dto.TenantID,
+		}).
+		Ignore().
+		Exec(ctx)
+
+	return s.invalidate(ctx, err)
+}
+
+// Unassign removes a role assignment created by Assign.
+func (s *Service) Unassign(ctx context.Context, dto *AssignRequest) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = db.NewDelete().
+		Model((*Assignment)(nil)).
+		Where("auth_user_id = ?", dto.AuthUserID).
+		Where("role_id = ?", dto.RoleID).
+		Where("tenant_id = ?", dto.TenantID).
+		Exec(ctx)
+
+	return s.invalidate(ctx, err)
+}
+
+// closure returns roleIDs along with every role they inherit, directly or
+// transitively.
+func (s *Service) closure(ctx context.Context, db bun.IDB, roleIDs []string) ([]string, error) {
+
+	seen := map[string]bool{}
+	res := []string{}
+	frontier := []string{}
+
+	for _, id := range roleIDs {
+		if !seen[id] {
+			seen[id] = true
+			res = append(res, id)
+			frontier = append(frontier, id)
+		}
+	}
+
+	for len(frontier) > 0 {
+		var inherited []string
+
+		err := db.NewSelect().
+			Model((*RoleInherit)(nil)).
+			Column("inherited_role_id").
+			Where("role_id IN (?)", bun.In(frontier)).
+			Scan(ctx, &inherited)
+
+		if err != nil {
+			return nil, err
+		}
+
+		frontier = frontier[:0]
+
+		for _, id := range inherited {
+			if !seen[id] {
+				seen[id] = true
+				res = append(res, id)
+				frontier = append(frontier, id)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func (s *Service) ensureRole(ctx context.Context, roleId string) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	exists, err := db.NewSelect().
+		Model((*Role)(nil)).
+		Where("id = ?", roleId).
+		Exists(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrRoleNotFound
+	}
+
+	return nil
+}
+
+// invalidate drops the request-scoped cache after a successful change so
+// later checks in the same request observe it.
+func (s *Service) invalidate(ctx context.Context, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if cache := cacheFromContext(ctx); cache != nil {
+		cache.clear()
+	}
+
+	return nil
+}
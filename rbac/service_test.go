@@ -0,0 +1,203 @@
+package rbac_test
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/joelywz/mo/auth"
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/joelywz/mo/rbac"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+var db *bun.DB
+
+func TestMain(m *testing.M) {
+	var (
+		purge func() error
+		err   error
+	)
+
+	db, purge, err = dbtest.Open("mo_rbac")
+
+	if err != nil {
+		log.Fatalf("Could not start database: %s", err)
+	}
+
+	// Migrate database
+	migrations := migrate.NewMigrations()
+	rbac.RegisterMigrations(migrations)
+
+	migrator := migrate.NewMigrator(db, migrations)
+
+	if err := migrator.Init(context.Background()); err != nil {
+		log.Fatalf("Could not init migrations: %s", err)
+	}
+
+	if _, err := migrator.Migrate(context.Background()); err != nil {
+		log.Fatalf("Could not migrate: %s", err)
+	}
+
+	code := m.Run()
+
+	if err := purge(); err != nil {
+		log.Fatalf("Could not purge resource: %s", err)
+	}
+
+	os.Exit(code)
+}
+
+func TestMigrations(t *testing.T) {
+
+	drifts, err := database.DetectDrift(context.Background(), db, rbac.Models()...)
+
+	assert.NoError(t, err)
+	assert.Empty(t, drifts, "migrations should match the models")
+}
+
+func TestService(t *testing.T) {
+
+	rbacService := rbac.NewService()
+
+	ctx := database.WithContext(context.Background(), db)
+
+	role := func(name string, permissions ...rbac.Permission) string {
+		res, err := rbacService.CreateRole(ctx, &rbac.CreateRoleRequest{Name: name})
+		assert.NoError(t, err, "create role should not return error")
+
+		for _, permission := range permissions {
+			assert.NoError(t, rbacService.GrantPermission(ctx, res.ID, permission))
+		}
+
+		return res.ID
+	}
+
+	can := func(ctx context.Context, authUserId string, permission rbac.Permission) bool {
+		ok, err := rbacService.Can(ctx, authUserId, permission)
+		assert.NoError(t, err, "can should not return error")
+
+		return ok
+	}
+
+	viewer := role("viewer", "orders:read")
+	editor := role("editor", "orders:write")
+	admin := role("admin", "*:*")
+
+	_, err := rbacService.CreateRole(ctx, &rbac.CreateRoleRequest{Name: "viewer"})
+	assert.ErrorIs(t, err, rbac.ErrRoleExists, "create role should return ErrRoleExists for a taken name")
+
+	assert.ErrorIs(t, rbacService.GrantPermission(ctx, viewer, "orders"), rbac.ErrInvalidPermission)
+	assert.ErrorIs(t, rbacService.Assign(ctx, &rbac.AssignRequest{AuthUserID: "user", RoleID: "missing"}), rbac.ErrRoleNotFound)
+
+	t.Run("Inheritance", func(t *testing.T) {
+		assert.NoError(t, rbacService.InheritRole(ctx, editor, viewer))
+		assert.NoError(t, rbacService.InheritRole(ctx, admin, editor))
+		assert.NoError(t, rbacService.Assign(ctx, &rbac.AssignRequest{AuthUserID: "editor", RoleID: editor}))
+		assert.NoError(t, rbacService.Assign(ctx, &rbac.AssignRequest{AuthUserID: "admin", RoleID: admin}))
+
+		assert.True(t, can(ctx, "editor", "orders:write"))
+		assert.True(t, can(ctx, "editor", "orders:read"), "editor should inherit the permissions of viewer")
+		assert.False(t, can(ctx, "editor", "invoices:read"))
+		assert.True(t, can(ctx, "admin", "invoices:read"))
+		assert.False(t, can(ctx, "nobody", "orders:read"))
+
+		permissions, err := rbacService.Permissions(ctx, "admin")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []rbac.Permission{"orders:read", "orders:write", "*:*"}, permissions, "admin should inherit transitively")
+
+		assert.NoError(t, rbacService.DisinheritRole(ctx, editor, viewer))
+		assert.False(t, can(ctx, "editor", "orders:read"))
+		assert.NoError(t, rbacService.InheritRole(ctx, editor, viewer))
+	})
+
+	t.Run("Cycles", func(t *testing.T) {
+		assert.ErrorIs(t, rbacService.InheritRole(ctx, viewer, admin), rbac.ErrInheritanceCycle)
+		assert.ErrorIs(t, rbacService.InheritRole(ctx, viewer, viewer), rbac.ErrInheritanceCycle)
+	})
+
+	t.Run("Tenants", func(t *testing.T) {
+		assert.NoError(t, rbacService.Assign(ctx, &rbac.AssignRequest{AuthUserID: "tenant", RoleID: viewer, TenantID: "t1"}))
+
+		assert.False(t, can(ctx, "tenant", "orders:read"), "tenant assignments should not apply globally")
+		assert.True(t, can(database.WithTenant(ctx, "t1"), "tenant", "orders:read"))
+		assert.False(t, can(database.WithTenant(ctx, "t2"), "tenant", "orders:read"))
+		assert.True(t, can(database.WithTenant(ctx, "t2"), "editor", "orders:write"), "global assignments should apply to every tenant")
+
+		assert.NoError(t, rbacService.Unassign(ctx, &rbac.AssignRequest{AuthUserID: "tenant", RoleID: viewer, TenantID: "t1"}))
+		assert.False(t, can(database.WithTenant(ctx, "t1"), "tenant", "orders:read"))
+	})
+
+	t.Run("Cache", func(t *testing.T) {
+		ctx := rbac.WithCache(ctx)
+
+		assert.True(t, can(ctx, "editor", "orders:write"))
+
+		// A change bypassing the service is not seen within the request
+		_, err := db.NewDelete().
+			Model((*rbac.Assignment)(nil)).
+			Where("auth_user_id = ?", "editor").
+			Exec(ctx)
+		assert.NoError(t, err)
+
+		assert.True(t, can(ctx, "editor", "orders:write"), "permissions should be cached")
+		assert.False(t, can(database.WithContext(context.Background(), db), "editor", "orders:write"))
+
+		// A change through the service is
+		assert.NoError(t, rbacService.Assign(ctx, &rbac.AssignRequest{AuthUserID: "editor", RoleID: viewer}))
+		assert.False(t, can(ctx, "editor", "orders:write"), "changes should invalidate the cache")
+		assert.True(t, can(ctx, "editor", "orders:read"))
+	})
+
+	t.Run("Middleware", func(t *testing.T) {
+		e := echo.New()
+		e.Use(database.GlobalMiddleware(db), rbac.CacheMiddleware())
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				if authUserId := c.Request().Header.Get("X-Auth-User"); authUserId != "" {
+					ctx := auth.WithContext(c.Request().Context(), &auth.VerifyResponse{AuthUserID: authUserId})
+					c.SetRequest(c.Request().WithContext(ctx))
+				}
+
+				return next(c)
+			}
+		})
+
+		e.GET("/", func(c echo.Context) error {
+			return c.NoContent(http.StatusNoContent)
+		}, rbac.Require(rbacService, "invoices:read"))
+
+		cases := map[string]int{
+			"admin":  http.StatusNoContent,
+			"editor": http.StatusForbidden,
+			"":       http.StatusUnauthorized,
+		}
+
+		for authUserId, status := range cases {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Auth-User", authUserId)
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, status, rec.Code, authUserId)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, rbacService.DeleteRole(ctx, admin))
+		assert.False(t, can(ctx, "admin", "invoices:read"))
+		assert.ErrorIs(t, rbacService.DeleteRole(ctx, admin), rbac.ErrRoleNotFound)
+
+		roles, err := rbacService.ListRoles(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, roles, 2)
+	})
+}
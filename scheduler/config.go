@@ -0,0 +1,16 @@
+package scheduler
+
+import (
+	"github.com/caarlos0/env/v11"
+)
+
+type Config struct {
+	// Timezone cron expressions are evaluated in.
+	Timezone string `env:"SCHEDULER_TIMEZONE" envDefault:"UTC"`
+	Disabled bool   `env:"SCHEDULER_DISABLED" envDefault:"false"`
+}
+
+func ParseConfig() (*Config, error) {
+	cfg, err := env.ParseAs[Config]()
+	return &cfg, err
+}
@@ -0,0 +1,10 @@
+CREATE TABLE scheduler_runs (
+  name VARCHAR(128) NOT NULL,
+  scheduled_at DATETIME NOT NULL,
+  started_at DATETIME NOT NULL,
+  finished_at DATETIME,
+  status VARCHAR(16) NOT NULL,
+  error TEXT NOT NULL,
+  updated_at DATETIME NOT NULL,
+  PRIMARY KEY (name)
+);
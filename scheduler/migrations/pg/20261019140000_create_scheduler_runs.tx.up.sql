@@ -0,0 +1,10 @@
+CREATE TABLE scheduler_runs (
+  name VARCHAR(128) NOT NULL,
+  scheduled_at TIMESTAMPTZ NOT NULL,
+  started_at TIMESTAMPTZ NOT NULL,
+  finished_at TIMESTAMPTZ,
+  status VARCHAR(16) NOT NULL,
+  error TEXT NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (name)
+);
@@ -0,0 +1,10 @@
+CREATE TABLE scheduler_runs (
+  name VARCHAR(128) NOT NULL,
+  scheduled_at TIMESTAMP NOT NULL,
+  started_at TIMESTAMP NOT NULL,
+  finished_at TIMESTAMP,
+  status VARCHAR(16) NOT NULL,
+  error TEXT NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (name)
+);
@@ -0,0 +1,380 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/robfig/cron/v3"
+	"github.com/uptrace/bun"
+	"go.uber.org/fx"
+)
+
+var (
+	ErrDuplicateTask = errors.New("duplicate task name")
+)
+
+// Module provides a Scheduler running every Task provided with AsTask.
+var Module = fx.Module("scheduler",
+	fx.Provide(ParseConfig, New),
+	fx.Invoke(Run),
+)
+
+type Params struct {
+	fx.In
+
+	DB     *bun.DB
+	Config *Config
+	Tasks  []Task `group:"scheduler_tasks"`
+	// Clock defaults to the system clock.
+	Clock Clock `optional:"true"`
+}
+
+// Clock tells the time to a Scheduler, so that tests can control it.
+type Clock interface {
+	Now() time.Time
+	// After sends the time on the returned channel once d elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+type entry struct {
+	task     Task
+	schedule cron.Schedule
+	running  atomic.Bool
+}
+
+// Scheduler runs tasks on their cron schedule. Every instance of an app
+// schedules every task, a database lock and the last recorded run make
+// sure each scheduled run only executes on one of them.
+type Scheduler struct {
+	db       *bun.DB
+	cfg      *Config
+	clock    Clock
+	location *time.Location
+	entries  []*entry
+
+	cancel     context.CancelFunc
+	cancelWork context.CancelFunc
+	done       chan struct{}
+	runs       sync.WaitGroup
+}
+
+func New(p Params) (*Scheduler, error) {
+
+	location, err := time.LoadLocation(p.Config.Timezone)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scheduler{
+		db:       p.DB,
+		cfg:      p.Config,
+		clock:    p.Clock,
+		location: location,
+	}
+
+	if s.clock == nil {
+		s.clock = systemClock{}
+	}
+
+	for _, task := range p.Tasks {
+		if err := s.Add(task); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Add registers a task. It must be called before Start.
+func (s *Scheduler) Add(task Task) error {
+
+	for _, e := range s.entries {
+		if e.task.Name == task.Name {
+			return fmt.Errorf("%w: %s", ErrDuplicateTask, task.Name)
+		}
+	}
+
+	schedule, err := cron.ParseStandard(task.Spec)
+
+	if err != nil {
+		return fmt.Errorf("task %s: %w", task.Name, err)
+	}
+
+	s.entries = append(s.entries, &entry{
+		task:     task,
+		schedule: schedule,
+	})
+
+	return nil
+}
+
+// Start runs the tasks on their schedule in the background until Stop is
+// called.
+func (s *Scheduler) Start() {
+	if s.cfg.Disabled || len(s.entries) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	workCtx, cancelWork := context.WithCancel(context.Background())
+
+	s.cancel = cancel
+	s.cancelWork = cancelWork
+	s.done = make(chan struct{})
+
+	go s.loop(ctx, workCtx)
+}
+
+// Stop stops scheduling and waits for running tasks to finish. If ctx is
+// done first, running tasks are cancelled.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+
+	s.cancel()
+	<-s.done
+
+	done := make(chan struct{})
+
+	go func() {
+		s.runs.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancelWork()
+		return nil
+	case <-ctx.Done():
+		s.cancelWork()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Status returns the last recorded run of every task that ran at least
+// once.
+func (s *Scheduler) Status(ctx context.Context) ([]TaskRun, error) {
+
+	runs := []TaskRun{}
+
+	if err := s.db.NewSelect().Model(&runs).Order("name").Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, workCtx context.Context) {
+	defer close(s.done)
+
+	next := make([]time.Time, len(s.entries))
+	now := s.clock.Now().In(s.location)
+
+	for i, e := range s.entries {
+		next[i] = e.schedule.Next(now)
+	}
+
+	for {
+		earliest := next[0]
+
+		for _, t := range next[1:] {
+			if t.Before(earliest) {
+				earliest = t
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(earliest.Sub(s.clock.Now())):
+		}
+
+		now := s.clock.Now().In(s.location)
+
+		for i, e := range s.entries {
+			if next[i].After(now) {
+				continue
+			}
+
+			s.fire(workCtx, e, next[i])
+
+			next[i] = e.schedule.Next(now)
+		}
+	}
+}
+
+func (s *Scheduler) fire(ctx context.Context, e *entry, scheduledAt time.Time) {
+
+	// Never overlap runs of the same task within this instance
+	if !e.running.CompareAndSwap(false, true) {
+		slog.Warn("skipping task, previous run still in progress", "task", e.task.Name)
+		return
+	}
+
+	s.runs.Add(1)
+
+	go func() {
+		defer func() {
+			e.running.Store(false)
+			s.runs.Done()
+		}()
+
+		if err := s.execute(ctx, e.task, scheduledAt); err != nil {
+			slog.Error("running task", "task", e.task.Name, "error", err)
+		}
+	}()
+}
+
+func (s *Scheduler) execute(ctx context.Context, task Task, scheduledAt time.Time) error {
+
+	release, err := database.Lock(ctx, s.db, "scheduler:"+task.Name, 0)
+
+	// Another instance is running the task
+	if errors.Is(err, database.ErrLockNotAcquired) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	var last TaskRun
+
+	err = s.db.NewSelect().Model(&last).Where("name = ?", task.Name).Scan(ctx)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	exists := err == nil
+
+	// Another instance already ran this slot
+	if exists && !last.ScheduledAt.Before(scheduledAt) {
+		return nil
+	}
+
+	run := TaskRun{
+		Name:        task.Name,
+		ScheduledAt: scheduledAt,
+		StartedAt:   s.clock.Now(),
+		Status:      RunStatusRunning,
+	}
+
+	// Claim the slot atomically, the lock alone does not exclude other
+	// instances on dialects without named locks
+	claimed, err := s.claim(ctx, &run, exists)
+
+	if err != nil {
+		return err
+	}
+
+	if !claimed {
+		return nil
+	}
+
+	slog.Info("running task", "task", task.Name, "scheduled", scheduledAt)
+
+	runErr := s.run(ctx, task)
+	finishedAt := s.clock.Now()
+
+	run.FinishedAt = &finishedAt
+	run.Status = RunStatusSucceeded
+
+	if runErr != nil {
+		run.Status = RunStatusFailed
+		run.Error = runErr.Error()
+	}
+
+	_, err = s.db.NewUpdate().
+		Model(&run).
+		Column("finished_at", "status", "error", "updated_at").
+		WherePK().
+		Exec(context.WithoutCancel(ctx))
+
+	return errors.Join(runErr, err)
+}
+
+// claim records run unless another instance recorded the same or a later
+// slot first.
+func (s *Scheduler) claim(ctx context.Context, run *TaskRun, exists bool) (bool, error) {
+
+	if !exists {
+		_, err := s.db.NewInsert().Model(run).Exec(ctx)
+
+		if errors.Is(database.ClassifyError(err), database.ErrUniqueViolation) {
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+
+	res, err := s.db.NewUpdate().
+		Model(run).
+		WherePK().
+		Where("scheduled_at < ?", run.ScheduledAt).
+		Exec(ctx)
+
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (s *Scheduler) run(ctx context.Context, task Task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("task panic: %v", r)
+		}
+	}()
+
+	if task.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, task.Timeout)
+		defer cancel()
+	}
+
+	return task.Run(database.WithContext(ctx, s.db))
+}
+
+// Run starts the scheduler with the fx lifecycle.
+func Run(lc fx.Lifecycle, s *Scheduler) {
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			s.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return s.Stop(ctx)
+		},
+	})
+}
@@ -0,0 +1,245 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/joelywz/mo/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+var db *bun.DB
+
+func TestMain(m *testing.M) {
+	var (
+		purge func() error
+		err   error
+	)
+
+	db, purge, err = dbtest.Open("mo_scheduler")
+
+	if err != nil {
+		log.Fatalf("Could not start database: %s", err)
+	}
+
+	// Migrate database
+	migrations := migrate.NewMigrations()
+	scheduler.RegisterMigrations(migrations)
+
+	migrator := migrate.NewMigrator(db, migrations)
+
+	if err := migrator.Init(context.Background()); err != nil {
+		log.Fatalf("Could not init migrations: %s", err)
+	}
+
+	if _, err := migrator.Migrate(context.Background()); err != nil {
+		log.Fatalf("Could not migrate: %s", err)
+	}
+
+	code := m.Run()
+
+	if err := purge(); err != nil {
+		log.Fatalf("Could not purge resource: %s", err)
+	}
+
+	os.Exit(code)
+}
+
+func TestMigrations(t *testing.T) {
+
+	drifts, err := database.DetectDrift(context.Background(), db, scheduler.Models()...)
+
+	assert.NoError(t, err)
+	assert.Empty(t, drifts, "migrations should match the models")
+}
+
+// fakeClock is a Clock only moving forward when advanced.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := waiter{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+
+	return w.c
+}
+
+// Advance moves the clock forward by d and wakes the waiters that are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	waiters := c.waiters[:0]
+
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+
+		w.c <- c.now
+	}
+
+	c.waiters = waiters
+}
+
+// Waiting reports how many callers of After are waiting.
+func (c *fakeClock) Waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.waiters)
+}
+
+// Reset forgets every waiter.
+func (c *fakeClock) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.waiters = nil
+}
+
+func TestScheduler(t *testing.T) {
+
+	cfg := &scheduler.Config{Timezone: "UTC"}
+
+	// tick advances clock by one second once every instance waits for it,
+	// then stops the instances once they have fired, which waits for the
+	// runs to finish.
+	tick := func(clock *fakeClock, instances ...*scheduler.Scheduler) {
+		for _, s := range instances {
+			s.Start()
+		}
+
+		waiting := func() bool { return clock.Waiting() == len(instances) }
+
+		assert.Eventually(t, waiting, 5*time.Second, time.Millisecond)
+		clock.Advance(time.Second)
+		assert.Eventually(t, waiting, 5*time.Second, time.Millisecond)
+
+		for _, s := range instances {
+			assert.NoError(t, s.Stop(context.Background()))
+		}
+
+		// The stopped instances leave their waiters behind
+		clock.Reset()
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := scheduler.New(scheduler.Params{
+			DB:     db,
+			Config: cfg,
+			Tasks:  []scheduler.Task{{Name: "bad", Spec: "not a spec"}},
+		})
+		assert.Error(t, err)
+
+		noop := func(context.Context) error { return nil }
+
+		_, err = scheduler.New(scheduler.Params{
+			DB:     db,
+			Config: cfg,
+			Tasks: []scheduler.Task{
+				{Name: "dup", Spec: "@hourly", Run: noop},
+				{Name: "dup", Spec: "@daily", Run: noop},
+			},
+		})
+		assert.ErrorIs(t, err, scheduler.ErrDuplicateTask)
+	})
+
+	t.Run("SingleRun", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+		var runs atomic.Int32
+
+		// Longer than the 64 characters of MySQL lock names
+		task := scheduler.Task{
+			Name: "count_" + strings.Repeat("x", 80),
+			Spec: "@every 1s",
+			Run: func(ctx context.Context) error {
+				runs.Add(1)
+				return nil
+			},
+		}
+
+		// Two instances share the database, each slot must only run once
+		var instances []*scheduler.Scheduler
+
+		for range 2 {
+			s, err := scheduler.New(scheduler.Params{DB: db, Config: cfg, Tasks: []scheduler.Task{task}, Clock: clock})
+			assert.NoError(t, err)
+
+			instances = append(instances, s)
+		}
+
+		for range 3 {
+			tick(clock, instances...)
+		}
+
+		assert.Equal(t, int32(3), runs.Load())
+
+		status, err := instances[0].Status(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, status, 1)
+		assert.Equal(t, scheduler.RunStatusSucceeded, status[0].Status)
+		assert.Equal(t, clock.Now(), status[0].ScheduledAt.UTC())
+		assert.NotNil(t, status[0].FinishedAt)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+		s, err := scheduler.New(scheduler.Params{
+			DB:     db,
+			Config: cfg,
+			Tasks: []scheduler.Task{{
+				Name: "fail",
+				Spec: "@every 1s",
+				Run: func(ctx context.Context) error {
+					return errors.New("boom")
+				},
+			}},
+			Clock: clock,
+		})
+		assert.NoError(t, err)
+
+		tick(clock, s)
+
+		var run scheduler.TaskRun
+
+		err = db.NewSelect().Model(&run).Where("name = ?", "fail").Scan(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, scheduler.RunStatusFailed, run.Status)
+		assert.Equal(t, "boom", run.Error)
+	})
+}
@@ -0,0 +1,59 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+	"go.uber.org/fx"
+)
+
+var _ bun.BeforeAppendModelHook = (*TaskRun)(nil)
+
+// Task is a recurring task. Spec is a standard five field cron expression,
+// such as "*/5 * * * *", or a descriptor such as "@hourly".
+type Task struct {
+	Name    string
+	Spec    string
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+// AsTask annotates a constructor returning a Task so that it is collected
+// by the scheduler.
+//
+//	fx.Provide(scheduler.AsTask(NewPurgeTask))
+func AsTask(f any) any {
+	return fx.Annotate(f, fx.ResultTags(`group:"scheduler_tasks"`))
+}
+
+type RunStatus string
+
+const (
+	RunStatusRunning   RunStatus = "RUNNING"
+	RunStatusSucceeded RunStatus = "SUCCEEDED"
+	RunStatusFailed    RunStatus = "FAILED"
+)
+
+// TaskRun records the last run of a task across every instance.
+type TaskRun struct {
+	bun.BaseModel `bun:"scheduler_runs"`
+	Name          string     `bun:"name,pk,notnull,type:varchar(128)"`
+	ScheduledAt   time.Time  `bun:"scheduled_at,notnull"`
+	StartedAt     time.Time  `bun:"started_at,notnull"`
+	FinishedAt    *time.Time `bun:"finished_at"`
+	Status        RunStatus  `bun:"status,notnull,type:varchar(16)"`
+	Error         string     `bun:"error,notnull,type:text"`
+	UpdatedAt     time.Time  `bun:"updated_at,notnull"`
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (r *TaskRun) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery, *bun.UpdateQuery:
+		r.UpdatedAt = time.Now()
+	}
+
+	return nil
+}
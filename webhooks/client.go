@@ -0,0 +1,73 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrForbiddenAddress = errors.New("webhook address not allowed")
+)
+
+// newClient returns the client deliveries are sent with by default. The
+// endpoint URLs come from customers, so it only dials public addresses,
+// checked once DNS resolved the host, and does not follow redirects.
+// Environment proxies are ignored since they would dial on its behalf.
+func newClient(timeout time.Duration) *http.Client {
+
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, c syscall.RawConn) error {
+
+			host, _, err := net.SplitHostPort(address)
+
+			if err != nil {
+				return err
+			}
+
+			addr, err := netip.ParseAddr(host)
+
+			if err != nil {
+				return err
+			}
+
+			if !isPublic(addr) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// isPublic reports whether addr may be reached by deliveries: it must not
+// be loopback, private, link-local, multicast or unspecified.
+func isPublic(addr netip.Addr) bool {
+
+	addr = addr.Unmap()
+
+	return !addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified()
+}
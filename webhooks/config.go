@@ -0,0 +1,23 @@
+package webhooks
+
+import (
+	"time"
+
+	"github.com/caarlos0/env/v11"
+)
+
+type Config struct {
+	// Queue deliveries are enqueued in, it must be worked on by a
+	// jobs.Runner.
+	Queue       string        `env:"WEBHOOKS_QUEUE" envDefault:"default"`
+	Timeout     time.Duration `env:"WEBHOOKS_TIMEOUT" envDefault:"10s"`
+	MaxAttempts int           `env:"WEBHOOKS_MAX_ATTEMPTS" envDefault:"10"`
+	// DisableAfter disables an endpoint after this many consecutive failed
+	// attempts.
+	DisableAfter int `env:"WEBHOOKS_DISABLE_AFTER" envDefault:"50"`
+}
+
+func ParseConfig() (*Config, error) {
+	cfg, err := env.ParseAs[Config]()
+	return &cfg, err
+}
@@ -0,0 +1,55 @@
+package webhooks
+
+import (
+	"context"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+var (
+	_ bun.BeforeAppendModelHook = (*Delivery)(nil)
+	_ database.IndexedModel     = (*Delivery)(nil)
+)
+
+// Delivery logs a single attempt to deliver a message to an endpoint.
+// StatusCode is zero when no response was received.
+type Delivery struct {
+	bun.BaseModel `bun:"webhook_deliveries"`
+	ID            string    `bun:"id,pk,notnull,type:varchar(32)"`
+	EndpointID    string    `bun:"endpoint_id,notnull,type:varchar(32)"`
+	MessageID     string    `bun:"message_id,notnull,type:varchar(32)"`
+	Event         string    `bun:"event,notnull,type:varchar(128)"`
+	Attempt       int       `bun:"attempt,notnull"`
+	StatusCode    int       `bun:"status_code,notnull"`
+	Error         string    `bun:"error,notnull,type:text"`
+	Response      string    `bun:"response,notnull,type:text"`
+	Duration      int64     `bun:"duration_ms,notnull"`
+	CreatedAt     time.Time `bun:"created_at,notnull"`
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (d *Delivery) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		d.CreatedAt = time.Now()
+	}
+
+	return nil
+}
+
+// Indexes implements database.IndexedModel.
+func (d *Delivery) Indexes() []database.Index {
+	return []database.Index{
+		{Name: "webhook_deliveries_endpoint_id_message_id_idx", Columns: []string{"endpoint_id", "message_id"}},
+		{Name: "webhook_deliveries_endpoint_id_created_at_idx", Columns: []string{"endpoint_id", "created_at"}},
+	}
+}
+
+// Succeeded reports whether the endpoint acknowledged the message with a
+// 2xx status.
+func (d *Delivery) Succeeded() bool {
+	return d.StatusCode >= 200 && d.StatusCode < 300
+}
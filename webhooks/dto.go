@@ -0,0 +1,29 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type CreateEndpointRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type EndpointResponse struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret is only returned when the endpoint is created.
+	Secret     string     `json:"secret,omitempty"`
+	DisabledAt *time.Time `json:"disabledAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// Payload is the JSON body posted to endpoints.
+type Payload struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
@@ -0,0 +1,54 @@
+package webhooks
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+var _ bun.BeforeAppendModelHook = (*Endpoint)(nil)
+
+// AllEvents subscribes an endpoint to every event.
+const AllEvents = "*"
+
+// Endpoint receives the events it is subscribed to. The secret is stored as
+// is since it is needed to sign payloads.
+type Endpoint struct {
+	bun.BaseModel `bun:"webhook_endpoints"`
+	ID            string     `bun:"id,pk,notnull,type:varchar(32)"`
+	URL           string     `bun:"url,notnull,type:varchar(2048)"`
+	Secret        string     `bun:"secret,notnull,type:varchar(64)"`
+	Events        string     `bun:"events,notnull,type:varchar(1024)"`
+	FailureCount  int        `bun:"failure_count,notnull"`
+	DisabledAt    *time.Time `bun:"disabled_at"`
+	CreatedAt     time.Time  `bun:"created_at,notnull"`
+	UpdatedAt     time.Time  `bun:"updated_at,notnull"`
+}
+
+// BeforeAppendModel implements schema.BeforeAppendModelHook.
+func (e *Endpoint) BeforeAppendModel(ctx context.Context, query schema.Query) error {
+	switch query.(type) {
+	case *bun.InsertQuery:
+		e.CreatedAt = time.Now()
+		e.UpdatedAt = time.Now()
+	case *bun.UpdateQuery:
+		e.UpdatedAt = time.Now()
+	}
+
+	return nil
+}
+
+// EventList returns the events the endpoint is subscribed to as a slice.
+func (e *Endpoint) EventList() []string {
+	return strings.Fields(e.Events)
+}
+
+// Subscribed reports whether the endpoint receives event.
+func (e *Endpoint) Subscribed(event string) bool {
+	events := e.EventList()
+	return slices.Contains(events, AllEvents) || slices.Contains(events, event)
+}
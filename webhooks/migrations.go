@@ -0,0 +1,44 @@
+package webhooks
+
+import (
+	"embed"
+	"fmt"
+
+	"github.com/joelywz/mo/database"
+	"github.com/uptrace/bun/migrate"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migrations creates and evolves the webhook tables. The SQL run depends on
+// the dialect of the database being migrated, see
+// database.DialectMigrations. The jobs table deliveries are queued in is
+// created by jobs.RegisterMigrations.
+var Migrations *migrate.Migrations
+
+func init() {
+
+	var err error
+
+	if Migrations, err = database.DialectMigrations(migrationFiles, "migrations"); err != nil {
+		panic(fmt.Sprintf("webhooks: %s", err))
+	}
+}
+
+// RegisterMigrations adds the webhooks migrations to m, so that they run
+// alongside the migrations of the app, see auth.RegisterMigrations.
+func RegisterMigrations(m *migrate.Migrations) {
+	for _, migration := range Migrations.Sorted() {
+		m.Add(migration)
+	}
+}
+
+// Models returns the models of the webhooks package, to check them against
+// the database with database.DetectDrift.
+func Models() []any {
+	return []any{
+		(*Endpoint)(nil),
+		(*Delivery)(nil),
+	}
+}
@@ -0,0 +1,29 @@
+CREATE TABLE webhook_endpoints (
+  id VARCHAR(32) NOT NULL,
+  url VARCHAR(2048) NOT NULL,
+  secret VARCHAR(64) NOT NULL,
+  events VARCHAR(1024) NOT NULL,
+  failure_count BIGINT NOT NULL,
+  disabled_at DATETIME,
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL,
+  PRIMARY KEY (id)
+);
+
+--bun:split
+
+CREATE TABLE webhook_deliveries (
+  id VARCHAR(32) NOT NULL,
+  endpoint_id VARCHAR(32) NOT NULL,
+  message_id VARCHAR(32) NOT NULL,
+  event VARCHAR(128) NOT NULL,
+  attempt BIGINT NOT NULL,
+  status_code BIGINT NOT NULL,
+  error TEXT NOT NULL,
+  response TEXT NOT NULL,
+  duration_ms BIGINT NOT NULL,
+  created_at DATETIME NOT NULL,
+  PRIMARY KEY (id),
+  INDEX webhook_deliveries_endpoint_id_message_id_idx (endpoint_id, message_id),
+  INDEX webhook_deliveries_endpoint_id_created_at_idx (endpoint_id, created_at)
+);
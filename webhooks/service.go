@@ -0,0 +1,443 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/jobs"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+const (
+	secretPrefix = "whsec_"
+	// responseLimit caps how much of a response body is kept in the
+	// delivery log.
+	responseLimit = 1024
+)
+
+var (
+	ErrEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrInvalidURL       = errors.New("invalid webhook url")
+	ErrNoEvents         = errors.New("webhook endpoint has no events")
+)
+
+var deliverKind = jobs.NewKind[deliverArgs]("webhooks.deliver")
+
+type deliverArgs struct {
+	EndpointID string `json:"endpointId"`
+	MessageID  string `json:"messageId"`
+	Event      string `json:"event"`
+	Body       string `json:"body"`
+}
+
+type Service struct {
+	cfg    *Config
+	client *http.Client
+}
+
+type Option func(s *Service)
+
+// WithHTTPClient sends deliveries with client instead of a client using
+// Config.Timeout. The default client refuses to dial private addresses and
+// does not follow redirects, client is trusted to protect itself.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *Service) {
+		s.client = client
+	}
+}
+
+func NewService(cfg *Config, opts ...Option) *Service {
+	s := &Service{
+		cfg:    cfg,
+		client: newClient(cfg.Timeout),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Register registers the delivery handler with a job runner. Failed
+// deliveries are retried by the runner with exponential backoff.
+func (s *Service) Register(r *jobs.Runner) {
+	jobs.Handle(r, deliverKind, s.deliver)
+}
+
+// CreateEndpoint registers an endpoint receiving dto.Events, or every event
+// with AllEvents. The generated signing secret is only returned once.
+func (s *Service) CreateEndpoint(ctx context.Context, dto *CreateEndpointRequest) (*EndpointResponse, error) {
+
+	u, err := url.Parse(dto.URL)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+
+	if len(dto.Events) == 0 {
+		return nil, ErrNoEvents
+	}
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := Endpoint{
+		ID:     gonanoid.Must(32),
+		URL:    dto.URL,
+		Secret: secretPrefix + gonanoid.Must(32),
+		Events: strings.Join(dto.Events, " "),
+	}
+
+	if _, err := db.NewInsert().Model(&endpoint).Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	res := toEndpointResponse(&endpoint)
+	res.Secret = endpoint.Secret
+
+	return res, nil
+}
+
+// Endpoints returns every registered endpoint.
+func (s *Service) Endpoints(ctx context.Context) ([]EndpointResponse, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []Endpoint
+
+	if err := db.NewSelect().Model(&endpoints).Order("created_at").Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	res := make([]EndpointResponse, 0, len(endpoints))
+
+	for i := range endpoints {
+		res = append(res, *toEndpointResponse(&endpoints[i]))
+	}
+
+	return res, nil
+}
+
+// DeleteEndpoint deletes an endpoint. Pending deliveries to it are dropped.
+func (s *Service) DeleteEndpoint(ctx context.Context, id string) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := db.NewDelete().Model((*Endpoint)(nil)).Where("id = ?", id).Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return ensureAffected(res)
+}
+
+// EnableEndpoint re-enables an endpoint that was disabled after failing
+// repeatedly.
+func (s *Service) EnableEndpoint(ctx context.Context, id string) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := db.NewUpdate().
+		Model((*Endpoint)(nil)).
+		Where("id = ?", id).
+		Set("failure_count = 0").
+		Set("disabled_at = NULL").
+		Set("updated_at = ?", time.Now()).
+		Exec(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return ensureAffected(res)
+}
+
+// Deliveries returns the most recent delivery attempts to an endpoint.
+func (s *Service) Deliveries(ctx context.Context, endpointId string, limit int) ([]Delivery, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := []Delivery{}
+
+	err = db.NewSelect().
+		Model(&deliveries).
+		Where("endpoint_id = ?", endpointId).
+		Order("created_at DESC").
+		Limit(limit).
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Publish queues event with JSON encoded data for every enabled endpoint
+// subscribed to it and returns the message ID. When the context carries a
+// transaction the deliveries are only queued once it commits.
+func (s *Service) Publish(ctx context.Context, event string, data any) (string, error) {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := json.Marshal(data)
+
+	if err != nil {
+		return "", err
+	}
+
+	payload := Payload{
+		ID:        gonanoid.Must(32),
+		Event:     event,
+		CreatedAt: time.Now(),
+		Data:      raw,
+	}
+
+	body, err := json.Marshal(payload)
+
+	if err != nil {
+		return "", err
+	}
+
+	var endpoints []Endpoint
+
+	err = db.NewSelect().
+		Model(&endpoints).
+		Where("disabled_at IS NULL").
+		Scan(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	for i := range endpoints {
+		if !endpoints[i].Subscribed(event) {
+			continue
+		}
+
+		_, err := deliverKind.Enqueue(ctx, deliverArgs{
+			EndpointID: endpoints[i].ID,
+			MessageID:  payload.ID,
+			Event:      event,
+			Body:       string(body),
+		}, jobs.InQueue(s.cfg.Queue), jobs.MaxAttempts(s.cfg.MaxAttempts))
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return payload.ID, nil
+}
+
+func (s *Service) deliver(ctx context.Context, args deliverArgs) error {
+
+	db, err := database.FromContext(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	var endpoint Endpoint
+
+	err = db.NewSelect().Model(&endpoint).Where("id = ?", args.EndpointID).Scan(ctx)
+
+	// Deleted or disabled endpoints drop their pending deliveries
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if endpoint.DisabledAt != nil {
+		return nil
+	}
+
+	delivery := s.send(ctx, &endpoint, args)
+	disabled := false
+
+	// The endpoint row is updated first so concurrent deliveries to it queue
+	// on its lock before numbering their attempt and counting failures.
+	err = database.RunInTx(ctx, nil, func(ctx context.Context) error {
+
+		tx, err := database.FromContext(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		update := tx.NewUpdate().
+			Model((*Endpoint)(nil)).
+			Where("id = ?", endpoint.ID).
+			Set("updated_at = ?", time.Now())
+
+		if delivery.Succeeded() {
+			update.Set("failure_count = 0")
+		} else {
+			update.Set("failure_count = failure_count + 1")
+		}
+
+		if _, err := update.Exec(ctx); err != nil {
+			return err
+		}
+
+		attempts, err := tx.NewSelect().
+			Model((*Delivery)(nil)).
+			Where("endpoint_id = ?", endpoint.ID).
+			Where("message_id = ?", args.MessageID).
+			Count(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		delivery.Attempt = attempts + 1
+
+		if _, err := tx.NewInsert().Model(delivery).Exec(ctx); err != nil {
+			return err
+		}
+
+		if delivery.Succeeded() {
+			return nil
+		}
+
+		res, err := tx.NewUpdate().
+			Model((*Endpoint)(nil)).
+			Where("id = ?", endpoint.ID).
+			Where("disabled_at IS NULL").
+			Where("failure_count >= ?", s.cfg.DisableAfter).
+			Set("disabled_at = ?", time.Now()).
+			Exec(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		disabled = err == nil && affected > 0
+
+		return nil
+	})
+
+	if err != nil || delivery.Succeeded() {
+		return err
+	}
+
+	// Stop retrying once the endpoint is disabled
+	if disabled {
+		slog.Warn("webhook endpoint disabled", "id", endpoint.ID, "url", endpoint.URL)
+		return nil
+	}
+
+	return errors.New(delivery.Error)
+}
+
+// send posts a message to endpoint and returns the logged attempt.
+func (s *Service) send(ctx context.Context, endpoint *Endpoint, args deliverArgs) *Delivery {
+
+	delivery := &Delivery{
+		ID:         gonanoid.Must(32),
+		EndpointID: endpoint.ID,
+		MessageID:  args.MessageID,
+		Event:      args.Event,
+	}
+
+	body := []byte(args.Body)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, args.MessageID)
+	req.Header.Set(HeaderEvent, args.Event)
+	req.Header.Set(HeaderSignature, Sign(endpoint.Secret, time.Now(), body))
+
+	start := time.Now()
+	res, err := s.client.Do(req)
+	delivery.Duration = time.Since(start).Milliseconds()
+
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+
+	defer res.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(res.Body, responseLimit))
+
+	delivery.StatusCode = res.StatusCode
+	delivery.Response = string(response)
+
+	if !delivery.Succeeded() {
+		delivery.Error = fmt.Sprintf("unexpected status %d", res.StatusCode)
+	}
+
+	return delivery
+}
+
+func ensureAffected(res sql.Result) error {
+
+	affected, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrEndpointNotFound
+	}
+
+	return nil
+}
+
+func toEndpointResponse(endpoint *Endpoint) *EndpointResponse {
+	return &EndpointResponse{
+		ID:         endpoint.ID,
+		URL:        endpoint.URL,
+		Events:     endpoint.EventList(),
+		DisabledAt: endpoint.DisabledAt,
+		CreatedAt:  endpoint.CreatedAt,
+	}
+}
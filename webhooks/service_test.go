@@ -0,0 +1,245 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joelywz/mo/database"
+	"github.com/joelywz/mo/internal/dbtest"
+	"github.com/joelywz/mo/jobs"
+	"github.com/joelywz/mo/webhooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+var db *bun.DB
+
+func TestMain(m *testing.M) {
+	var (
+		purge func() error
+		err   error
+	)
+
+	db, purge, err = dbtest.Open("mo_webhooks")
+
+	if err != nil {
+		log.Fatalf("Could not start database: %s", err)
+	}
+
+	// Migrate database
+	migrations := migrate.NewMigrations()
+	jobs.RegisterMigrations(migrations)
+	webhooks.RegisterMigrations(migrations)
+
+	migrator := migrate.NewMigrator(db, migrations)
+
+	if err := migrator.Init(context.Background()); err != nil {
+		log.Fatalf("Could not init migrations: %s", err)
+	}
+
+	if _, err := migrator.Migrate(context.Background()); err != nil {
+		log.Fatalf("Could not migrate: %s", err)
+	}
+
+	code := m.Run()
+
+	if err := purge(); err != nil {
+		log.Fatalf("Could not purge resource: %s", err)
+	}
+
+	os.Exit(code)
+}
+
+func TestMigrations(t *testing.T) {
+
+	drifts, err := database.DetectDrift(context.Background(), db, webhooks.Models()...)
+
+	assert.NoError(t, err)
+	assert.Empty(t, drifts, "migrations should match the models")
+}
+
+func TestWebhooks(t *testing.T) {
+
+	ctx := database.WithContext(context.Background(), db)
+
+	service := webhooks.NewService(&webhooks.Config{
+		Queue:        jobs.DefaultQueue,
+		Timeout:      time.Second,
+		MaxAttempts:  5,
+		DisableAfter: 2,
+	}, webhooks.WithHTTPClient(http.DefaultClient))
+
+	runner := jobs.NewRunner(db, &jobs.Config{
+		Queues:       map[string]int{jobs.DefaultQueue: 2},
+		PollInterval: 50 * time.Millisecond,
+		MaxAttempts:  5,
+		BaseBackoff:  10 * time.Millisecond,
+		MaxBackoff:   50 * time.Millisecond,
+		RescueAfter:  time.Hour,
+	})
+
+	service.Register(runner)
+	runner.Start()
+
+	defer runner.Stop(context.Background())
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := service.CreateEndpoint(ctx, &webhooks.CreateEndpointRequest{URL: "ftp://example.com", Events: []string{webhooks.AllEvents}})
+		assert.ErrorIs(t, err, webhooks.ErrInvalidURL)
+
+		_, err = service.CreateEndpoint(ctx, &webhooks.CreateEndpointRequest{URL: "https://example.com"})
+		assert.ErrorIs(t, err, webhooks.ErrNoEvents)
+	})
+
+	t.Run("Forbidden", func(t *testing.T) {
+		var calls atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+		}))
+		defer server.Close()
+
+		// The default client must refuse the loopback address of the server
+		guarded := webhooks.NewService(&webhooks.Config{
+			Queue:        "guarded",
+			Timeout:      time.Second,
+			MaxAttempts:  1,
+			DisableAfter: 10,
+		})
+
+		guardedRunner := jobs.NewRunner(db, &jobs.Config{
+			Queues:       map[string]int{"guarded": 1},
+			PollInterval: 50 * time.Millisecond,
+			MaxAttempts:  1,
+			RescueAfter:  time.Hour,
+		})
+
+		guarded.Register(guardedRunner)
+		guardedRunner.Start()
+
+		defer guardedRunner.Stop(context.Background())
+
+		endpoint, err := guarded.CreateEndpoint(ctx, &webhooks.CreateEndpointRequest{
+			URL:    server.URL,
+			Events: []string{"user.guarded"},
+		})
+		assert.NoError(t, err)
+
+		_, err = guarded.Publish(ctx, "user.guarded", nil)
+		assert.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			deliveries, err := guarded.Deliveries(ctx, endpoint.ID, 10)
+			return err == nil && len(deliveries) == 1
+		}, 5*time.Second, 50*time.Millisecond)
+
+		deliveries, err := guarded.Deliveries(ctx, endpoint.ID, 10)
+		assert.NoError(t, err)
+		assert.False(t, deliveries[0].Succeeded())
+		assert.Contains(t, deliveries[0].Error, webhooks.ErrForbiddenAddress.Error())
+		assert.EqualValues(t, 0, calls.Load())
+
+		assert.NoError(t, guarded.DeleteEndpoint(ctx, endpoint.ID))
+	})
+
+	t.Run("Deliver", func(t *testing.T) {
+		received := make(chan webhooks.Payload, 1)
+		var secret string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+
+			if err := webhooks.Verify(secret, r.Header.Get(webhooks.HeaderSignature), body, time.Minute); err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			var payload webhooks.Payload
+			json.Unmarshal(body, &payload)
+
+			received <- payload
+		}))
+		defer server.Close()
+
+		endpoint, err := service.CreateEndpoint(ctx, &webhooks.CreateEndpointRequest{
+			URL:    server.URL,
+			Events: []string{"user.registered"},
+		})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, endpoint.Secret)
+
+		secret = endpoint.Secret
+
+		// Not subscribed
+		_, err = service.Publish(ctx, "user.revoked", nil)
+		assert.NoError(t, err)
+
+		id, err := service.Publish(ctx, "user.registered", map[string]string{"email": "test@example.com"})
+		assert.NoError(t, err)
+
+		select {
+		case payload := <-received:
+			assert.Equal(t, id, payload.ID)
+			assert.Equal(t, "user.registered", payload.Event)
+			assert.JSONEq(t, `{"email":"test@example.com"}`, string(payload.Data))
+		case <-time.After(5 * time.Second):
+			t.Fatal("webhook not delivered")
+		}
+
+		assert.Eventually(t, func() bool {
+			deliveries, err := service.Deliveries(ctx, endpoint.ID, 10)
+			return err == nil && len(deliveries) == 1 && deliveries[0].Succeeded()
+		}, 5*time.Second, 50*time.Millisecond)
+
+		assert.NoError(t, service.DeleteEndpoint(ctx, endpoint.ID))
+		assert.ErrorIs(t, service.DeleteEndpoint(ctx, endpoint.ID), webhooks.ErrEndpointNotFound)
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		var calls atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		endpoint, err := service.CreateEndpoint(ctx, &webhooks.CreateEndpointRequest{
+			URL:    server.URL,
+			Events: []string{webhooks.AllEvents},
+		})
+		assert.NoError(t, err)
+
+		_, err = service.Publish(ctx, "user.linked", nil)
+		assert.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			endpoints, err := service.Endpoints(ctx)
+			return err == nil && len(endpoints) == 1 && endpoints[0].DisabledAt != nil
+		}, 5*time.Second, 50*time.Millisecond)
+
+		// Give the runner a chance to retry a disabled endpoint
+		time.Sleep(200 * time.Millisecond)
+		assert.EqualValues(t, 2, calls.Load())
+
+		deliveries, err := service.Deliveries(ctx, endpoint.ID, 10)
+		assert.NoError(t, err)
+		assert.Len(t, deliveries, 2)
+		assert.Equal(t, http.StatusInternalServerError, deliveries[0].StatusCode)
+
+		assert.NoError(t, service.EnableEndpoint(ctx, endpoint.ID))
+
+		endpoints, err := service.Endpoints(ctx)
+		assert.NoError(t, err)
+		assert.Nil(t, endpoints[0].DisabledAt)
+	})
+}
@@ -0,0 +1,85 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderID        = "Webhook-Id"
+	HeaderEvent     = "Webhook-Event"
+	HeaderSignature = "Webhook-Signature"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrSignatureExpired = errors.New("webhook signature expired")
+)
+
+// Sign returns the signature header value for payload sent at t, in the
+// form "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<payload>">".
+func Sign(secret string, t time.Time, payload []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, signature(secret, ts, payload))
+}
+
+// Verify checks a signature header created by Sign. Signatures with a
+// timestamp more than tolerance away from now, in either direction, are
+// rejected to prevent replays, a zero tolerance disables the check.
+func Verify(secret string, header string, payload []byte, tolerance time.Duration) error {
+
+	var ts string
+	var sigs []string
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sigs = append(sigs, value)
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidSignature
+	}
+
+	// A timestamp in the future would keep the signature valid for longer
+	// than tolerance
+	if age := time.Since(time.Unix(unix, 0)); tolerance > 0 && (age > tolerance || age < -tolerance) {
+		return ErrSignatureExpired
+	}
+
+	expected := signature(secret, ts, payload)
+
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+func signature(secret string, ts string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,31 @@
+package webhooks_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joelywz/mo/webhooks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignature(t *testing.T) {
+
+	payload := []byte(`{"event":"user.registered"}`)
+
+	header := webhooks.Sign("secret", time.Now(), payload)
+
+	assert.NoError(t, webhooks.Verify("secret", header, payload, time.Minute))
+	assert.ErrorIs(t, webhooks.Verify("other", header, payload, time.Minute), webhooks.ErrInvalidSignature)
+	assert.ErrorIs(t, webhooks.Verify("secret", header, []byte(`{}`), time.Minute), webhooks.ErrInvalidSignature)
+	assert.ErrorIs(t, webhooks.Verify("secret", "garbage", payload, time.Minute), webhooks.ErrInvalidSignature)
+
+	old := webhooks.Sign("secret", time.Now().Add(-time.Hour), payload)
+
+	assert.ErrorIs(t, webhooks.Verify("secret", old, payload, time.Minute), webhooks.ErrSignatureExpired)
+	assert.NoError(t, webhooks.Verify("secret", old, payload, 0))
+
+	future := webhooks.Sign("secret", time.Now().Add(time.Hour), payload)
+
+	assert.ErrorIs(t, webhooks.Verify("secret", future, payload, time.Minute), webhooks.ErrSignatureExpired)
+	assert.NoError(t, webhooks.Verify("secret", future, payload, 0))
+}